@@ -1,6 +1,8 @@
 package geode_go_client
 
 import (
+	"context"
+
 	"github.com/gemfire/geode-go-client/connector"
 	. "github.com/gemfire/geode-go-client/query"
 )
@@ -18,13 +20,20 @@ import (
 //     float64
 //     []byte
 //     string
-//     CustomEncodedValue
+//     connector.CustomEncodedValue
+//
+// Values of other types are encoded by the connector.ValueSerializer set on the pool, if it
+// handles them, and otherwise as JSON.
 //
 // In order to enable the protobuf protocol, the Geode servers must be started with the
 // property:
 //
 //     geode.feature-protobuf-protocol=true
 //
+// Each operation has a variant taking a context.Context, such as GetContext. A deadline on the
+// context is applied to the connection used for the operation. If the context is cancelled while
+// the operation is in progress, the operation returns the context's error and the connection is
+// discarded rather than being returned to the pool.
 type Client struct {
 	connector *connector.Protobuf
 }
@@ -35,16 +44,68 @@ func NewGeodeClient(c *connector.Protobuf) *Client {
 	}
 }
 
+// WithServerGroup returns a Client which performs all operations against servers in the given
+// server group. The returned Client shares its connection pool with this Client. Server groups
+// are resolved by locators, so the pool must have at least one locator.
+func (this *Client) WithServerGroup(group string) *Client {
+	return &Client{
+		connector: this.connector.WithServerGroup(group),
+	}
+}
+
+// WithIdentity returns a Client which performs all operations as the given identity, so that the
+// servers authorize them for that principal. The returned Client shares its connection pool with
+// this Client, but only uses connections which were authenticated as the identity.
+func (this *Client) WithIdentity(identity *connector.Identity) *Client {
+	return &Client{
+		connector: this.connector.WithIdentity(identity),
+	}
+}
+
+// ServerInfo returns the address of a server the Client uses and the protocol version negotiated
+// with it.
+func (this *Client) ServerInfo() (connector.ServerInfo, error) {
+	return this.connector.ServerInfo(context.Background())
+}
+
+// ServerInfoContext is like ServerInfo but waiting for a connection is bounded by the given
+// context.
+func (this *Client) ServerInfoContext(ctx context.Context) (connector.ServerInfo, error) {
+	return this.connector.ServerInfo(ctx)
+}
+
+// Shutdown stops the Client, waiting for operations in progress to complete until the context is
+// done. Every connection is then closed after telling the server that the client is disconnecting.
+// Clients created with WithServerGroup or WithIdentity share the same pool and are also shut down.
+func (this *Client) Shutdown(ctx context.Context) error {
+	return this.connector.Shutdown(ctx)
+}
+
+// Close stops the Client immediately. Operations which are in progress fail.
+func (this *Client) Close() error {
+	return this.connector.Close()
+}
+
 // Put data into a region. key and value must be a supported type.
 func (this *Client) Put(region string, key, value interface{}) error {
 	return this.connector.Put(region, key, value)
 }
 
+// PutContext is like Put but the operation is bounded by the given context.
+func (this *Client) PutContext(ctx context.Context, region string, key, value interface{}) error {
+	return this.connector.PutContext(ctx, region, key, value)
+}
+
 // Put data into a region if the key is not present. key and value must be a supported type.
 func (this *Client) PutIfAbsent(region string, key, value interface{}) error {
 	return this.connector.PutIfAbsent(region, key, value)
 }
 
+// PutIfAbsentContext is like PutIfAbsent but the operation is bounded by the given context.
+func (this *Client) PutIfAbsentContext(ctx context.Context, region string, key, value interface{}) error {
+	return this.connector.PutIfAbsentContext(ctx, region, key, value)
+}
+
 // Get an entry from a region using the specified key. It is the callers' responsibility
 // to perform any type-assertion on the returned value. If a single, optional value is
 // passed, the data retrieved from the region will be attempted to be unmarshalled as JSON
@@ -56,6 +117,14 @@ func (this *Client) Get(region string, key interface{}, value ...interface{}) (i
 	return this.connector.Get(region, key, nil)
 }
 
+// GetContext is like Get but the operation is bounded by the given context.
+func (this *Client) GetContext(ctx context.Context, region string, key interface{}, value ...interface{}) (interface{}, error) {
+	if len(value) > 0 {
+		return this.connector.GetContext(ctx, region, key, value[0])
+	}
+	return this.connector.GetContext(ctx, region, key, nil)
+}
+
 // PutAll adds multiple key/value pairs to a single region. Entries must be in the form of
 // a map. The returned values are either a map of individual keys and the associated error
 // when attempting to add that key, or a single error which typically would be as a result
@@ -64,6 +133,11 @@ func (this *Client) PutAll(region string, entries interface{}) (map[interface{}]
 	return this.connector.PutAll(region, entries)
 }
 
+// PutAllContext is like PutAll but the operation is bounded by the given context.
+func (this *Client) PutAllContext(ctx context.Context, region string, entries interface{}) (map[interface{}]error, error) {
+	return this.connector.PutAllContext(ctx, region, entries)
+}
+
 // GetAll returns the values of multiple keys. Keys must be passed as an array or slice.
 // The returned values are a map of keys and values for those keys which were
 // successfully retrieved, a map of keys and the relevant error for those keys which produced
@@ -73,11 +147,54 @@ func (this *Client) GetAll(region string, keys interface{}) (map[interface{}]int
 	return this.connector.GetAll(region, keys)
 }
 
+// GetAllContext is like GetAll but the operation is bounded by the given context.
+func (this *Client) GetAllContext(ctx context.Context, region string, keys interface{}) (map[interface{}]interface{}, map[interface{}]error, error) {
+	return this.connector.GetAllContext(ctx, region, keys)
+}
+
+// GetAllBatch is like GetAll, but returns a BatchResult describing the outcome of each key,
+// including the error code of each key which failed.
+func (this *Client) GetAllBatch(region string, keys interface{}) (*connector.BatchResult, error) {
+	return this.connector.GetAllBatch(region, keys)
+}
+
+// GetAllBatchContext is like GetAllBatch but the operation is bounded by the given context.
+func (this *Client) GetAllBatchContext(ctx context.Context, region string, keys interface{}) (*connector.BatchResult, error) {
+	return this.connector.GetAllBatchContext(ctx, region, keys)
+}
+
+// PutAllBatch is like PutAll, but returns a BatchResult describing the outcome of each key,
+// including the error code of each key which failed.
+func (this *Client) PutAllBatch(region string, entries interface{}) (*connector.BatchResult, error) {
+	return this.connector.PutAllBatch(region, entries)
+}
+
+// PutAllBatchContext is like PutAllBatch but the operation is bounded by the given context.
+func (this *Client) PutAllBatchContext(ctx context.Context, region string, entries interface{}) (*connector.BatchResult, error) {
+	return this.connector.PutAllBatchContext(ctx, region, entries)
+}
+
+// Resubmit sends the keys of a GetAllBatch or PutAllBatch which failed for reasons which may be
+// temporary again, in a single request. The returned BatchResult only describes those keys.
+func (this *Client) Resubmit(result *connector.BatchResult) (*connector.BatchResult, error) {
+	return this.connector.Resubmit(result)
+}
+
+// ResubmitContext is like Resubmit but the operation is bounded by the given context.
+func (this *Client) ResubmitContext(ctx context.Context, result *connector.BatchResult) (*connector.BatchResult, error) {
+	return this.connector.ResubmitContext(ctx, result)
+}
+
 // Remove an entry for a region.
 func (this *Client) Remove(region string, key interface{}) error {
 	return this.connector.Remove(region, key)
 }
 
+// RemoveContext is like Remove but the operation is bounded by the given context.
+func (this *Client) RemoveContext(ctx context.Context, region string, key interface{}) error {
+	return this.connector.RemoveContext(ctx, region, key)
+}
+
 // Remove many entries from a region. The keys must be passed as an array or slice.
 // Currently still being implemented in Geode.
 //func (this *Client) RemoveAll(region string, keys interface{}) error {
@@ -89,35 +206,70 @@ func (this *Client) Size(region string) (int32, error) {
 	return this.connector.Size(region)
 }
 
+// SizeContext is like Size but the operation is bounded by the given context.
+func (this *Client) SizeContext(ctx context.Context, region string) (int32, error) {
+	return this.connector.SizeContext(ctx, region)
+}
+
 // Execute a function on a region. This will execute on all members hosting the region and return a slice
 // of results; one entry for each member.
 func (this *Client) ExecuteOnRegion(functionId, region string, functionArgs interface{}, keyFilter []interface{}) ([]interface{}, error) {
 	return this.connector.ExecuteOnRegion(functionId, region, functionArgs, keyFilter)
 }
 
+// ExecuteOnRegionContext is like ExecuteOnRegion but the operation is bounded by the given context.
+func (this *Client) ExecuteOnRegionContext(ctx context.Context, functionId, region string, functionArgs interface{}, keyFilter []interface{}) ([]interface{}, error) {
+	return this.connector.ExecuteOnRegionContext(ctx, functionId, region, functionArgs, keyFilter)
+}
+
 // Execute a function on a list of members, returning a slice of results, one entry for each member.
 func (this *Client) ExecuteOnMembers(functionId string, members []string, functionArgs interface{}) ([]interface{}, error) {
 	return this.connector.ExecuteOnMembers(functionId, members, functionArgs)
 }
 
+// ExecuteOnMembersContext is like ExecuteOnMembers but the operation is bounded by the given context.
+func (this *Client) ExecuteOnMembersContext(ctx context.Context, functionId string, members []string, functionArgs interface{}) ([]interface{}, error) {
+	return this.connector.ExecuteOnMembersContext(ctx, functionId, members, functionArgs)
+}
+
 // Execute a function on a list of group. This will execute on each member associated with the groups;
 // returning a slice of results, one entry for each member.
 func (this *Client) ExecuteOnGroups(functionId string, groups []string, functionArgs interface{}) ([]interface{}, error) {
 	return this.connector.ExecuteOnGroups(functionId, groups, functionArgs)
 }
 
+// ExecuteOnGroupsContext is like ExecuteOnGroups but the operation is bounded by the given context.
+func (this *Client) ExecuteOnGroupsContext(ctx context.Context, functionId string, groups []string, functionArgs interface{}) ([]interface{}, error) {
+	return this.connector.ExecuteOnGroupsContext(ctx, functionId, groups, functionArgs)
+}
+
 // Execute a query, returning a single result value.
 func (this *Client) QueryForSingleResult(query *Query) (interface{}, error){
 	return this.connector.QuerySingleResult(query)
 }
 
+// QueryForSingleResultContext is like QueryForSingleResult but the query is bounded by the given context.
+func (this *Client) QueryForSingleResultContext(ctx context.Context, query *Query) (interface{}, error) {
+	return this.connector.QuerySingleResultContext(ctx, query)
+}
+
 // Execute a query, returning a list of results.
 func (this *Client) QueryForListResult(query *Query) ([]interface{}, error){
 	return this.connector.QueryListResult(query)
 }
 
+// QueryForListResultContext is like QueryForListResult but the query is bounded by the given context.
+func (this *Client) QueryForListResultContext(ctx context.Context, query *Query) ([]interface{}, error) {
+	return this.connector.QueryListResultContext(ctx, query)
+}
+
 // Execute a query, returning a map of column (or field) names and the associated values for each column.
 func (this *Client) QueryForTableResult(query *Query) (map[string][]interface{}, error){
 	return this.connector.QueryTableResult(query)
 }
 
+// QueryForTableResultContext is like QueryForTableResult but the query is bounded by the given context.
+func (this *Client) QueryForTableResultContext(ctx context.Context, query *Query) (map[string][]interface{}, error) {
+	return this.connector.QueryTableResultContext(ctx, query)
+}
+
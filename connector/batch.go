@@ -0,0 +1,317 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// A BatchResult is the outcome of each key of a GetAll or PutAll. Keys are those given by the
+// caller, so they can be used to look up the caller's own data.
+type BatchResult struct {
+	// Operation is either "GetAll" or "PutAll".
+	Operation string
+	Region    string
+
+	// Succeeded holds the keys which were retrieved or stored.
+	Succeeded []interface{}
+
+	// Values holds the value retrieved by a GetAll for each key in Succeeded, in the same order.
+	// Values are not held in a map, as keys such as byte slices cannot be map keys. It is nil for
+	// a PutAll.
+	Values []interface{}
+
+	// Failures holds the keys which were not retrieved or stored, and why.
+	Failures []*KeyFailure
+}
+
+// A KeyFailure describes why a single key of a GetAll or PutAll failed. Err is usually a
+// *ServerError, but may also be an error decoding the key's value.
+type KeyFailure struct {
+	Key interface{}
+	Err error
+
+	// The key and entry as sent to the server, so that they can be resubmitted
+	key   *v1.EncodedValue
+	entry *v1.Entry
+}
+
+// Code returns the ErrorCode sent by the server for the key, or ErrorCode_INVALID_ERROR_CODE if
+// the failure occurred in the client.
+func (this *KeyFailure) Code() v1.ErrorCode {
+	return errorCode(this.Err)
+}
+
+// Retryable returns true if the key failed for a reason which may be temporary, so that it may
+// succeed if resubmitted.
+func (this *KeyFailure) Retryable() bool {
+	switch this.Code() {
+	case v1.ErrorCode_SERVER_ERROR, v1.ErrorCode_NO_AVAILABLE_SERVER:
+		return true
+	}
+
+	return false
+}
+
+// resubmittable returns true if the key can be sent again.
+func (this *KeyFailure) resubmittable(operation string) bool {
+	if operation == "PutAll" {
+		return this.entry != nil
+	}
+
+	return this.key != nil
+}
+
+// FailedKeys returns the keys which failed.
+func (this *BatchResult) FailedKeys() []interface{} {
+	keys := make([]interface{}, 0, len(this.Failures))
+	for _, f := range this.Failures {
+		keys = append(keys, f.Key)
+	}
+
+	return keys
+}
+
+// Retryable returns the keys which failed for reasons which may be temporary. These are the keys
+// which Resubmit sends again.
+func (this *BatchResult) Retryable() []interface{} {
+	var keys []interface{}
+	for _, f := range this.Failures {
+		if f.Retryable() {
+			keys = append(keys, f.Key)
+		}
+	}
+
+	return keys
+}
+
+// batchKeys matches the keys in a response with the keys given by the caller. If the caller's
+// keys are nil, the keys in the response are decoded instead.
+type batchKeys struct {
+	keys    []interface{}
+	encoded []*v1.EncodedValue
+	index   map[string]int
+}
+
+func newBatchKeys(keys []interface{}, encoded []*v1.EncodedValue) *batchKeys {
+	return &batchKeys{
+		keys:    keys,
+		encoded: encoded,
+	}
+}
+
+// find returns the position of a key in the request, or -1 if the server returned a key which was
+// not sent, for example because it converted the key to another type.
+func (this *batchKeys) find(key *v1.EncodedValue) int {
+	if this.keys == nil {
+		return -1
+	}
+
+	if this.index == nil {
+		this.index = make(map[string]int, len(this.encoded))
+		for i, k := range this.encoded {
+			data, err := proto.Marshal(k)
+			if err == nil {
+				this.index[string(data)] = i
+			}
+		}
+	}
+
+	data, err := proto.Marshal(key)
+	if err != nil {
+		return -1
+	}
+
+	if i, ok := this.index[string(data)]; ok {
+		return i
+	}
+
+	return -1
+}
+
+// resolveBatchKey returns the position and caller's key for a key in the response, along with
+// the key as sent. Keys which were not sent are decoded instead.
+func (this *Protobuf) resolveBatchKey(keys *batchKeys, key *v1.EncodedValue, operation string) (int, interface{}, *v1.EncodedValue, error) {
+	if i := keys.find(key); i >= 0 {
+		return i, keys.keys[i], keys.encoded[i], nil
+	}
+
+	decoded, err := this.decodeValue(key, nil)
+	if err != nil {
+		return -1, nil, nil, errors.New(fmt.Sprintf("unable to decode %s response key: %s", operation, err.Error()))
+	}
+
+	return -1, decoded, key, nil
+}
+
+// GetAllBatch is like GetAll, but returns the outcome of each key as a BatchResult.
+func (this *Protobuf) GetAllBatch(region string, keys interface{}) (*BatchResult, error) {
+	return this.GetAllBatchContext(context.Background(), region, keys)
+}
+
+func (this *Protobuf) GetAllBatchContext(ctx context.Context, region string, keys interface{}) (*BatchResult, error) {
+	getAll, originalKeys, err := this.newGetAllRequest(region, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return this.getAllBatch(ctx, region, originalKeys, getAll.GetGetAllRequest().GetKey())
+}
+
+// getAllBatch retrieves the encoded keys, reporting each using the matching key in originalKeys,
+// or the key decoded from the response if originalKeys is nil.
+func (this *Protobuf) getAllBatch(ctx context.Context, region string, originalKeys []interface{}, encodedKeys []*v1.EncodedValue) (*BatchResult, error) {
+	result := &BatchResult{
+		Operation: "GetAll",
+		Region:    region,
+	}
+
+	if len(encodedKeys) == 0 {
+		return result, nil
+	}
+
+	getAll := newGetAllMessage(region, encodedKeys)
+	response, server, err := this.performOperation(ctx, getAll)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := newBatchKeys(originalKeys, encodedKeys)
+
+	for _, entry := range response.GetGetAllResponse().GetEntries() {
+		_, key, _, err := this.resolveBatchKey(keys, entry.Key, "GetAll")
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := this.decodeValue(entry.Value, nil)
+		if err != nil {
+			result.Failures = append(result.Failures, &KeyFailure{
+				Key: key,
+				Err: errors.New(fmt.Sprintf("unable to decode GetAll value for key: %v: %s", key, err.Error())),
+			})
+			continue
+		}
+
+		result.Succeeded = append(result.Succeeded, key)
+		result.Values = append(result.Values, value)
+	}
+
+	for _, failure := range response.GetGetAllResponse().GetFailures() {
+		_, key, sent, err := this.resolveBatchKey(keys, failure.Key, "GetAll")
+		if err != nil {
+			return nil, err
+		}
+
+		result.Failures = append(result.Failures, &KeyFailure{
+			Key: key,
+			Err: newServerError(failure.GetError(), getAll, server),
+			key: sent,
+		})
+	}
+
+	return result, nil
+}
+
+// PutAllBatch is like PutAll, but returns the outcome of each key as a BatchResult.
+func (this *Protobuf) PutAllBatch(region string, entries interface{}) (*BatchResult, error) {
+	return this.PutAllBatchContext(context.Background(), region, entries)
+}
+
+func (this *Protobuf) PutAllBatchContext(ctx context.Context, region string, entries interface{}) (*BatchResult, error) {
+	putAll, originalKeys, err := this.newPutAllRequest(region, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return this.putAllBatch(ctx, region, originalKeys, putAll.GetPutAllRequest().GetEntry())
+}
+
+// putAllBatch stores the encoded entries, reporting each key using the matching key in
+// originalKeys, or the key decoded from the response if originalKeys is nil.
+func (this *Protobuf) putAllBatch(ctx context.Context, region string, originalKeys []interface{}, encodedEntries []*v1.Entry) (*BatchResult, error) {
+	result := &BatchResult{
+		Operation: "PutAll",
+		Region:    region,
+	}
+
+	if len(encodedEntries) == 0 {
+		return result, nil
+	}
+
+	putAll := newPutAllMessage(region, encodedEntries)
+	response, server, err := this.performOperation(ctx, putAll)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedKeys := make([]*v1.EncodedValue, len(encodedEntries))
+	for i, e := range encodedEntries {
+		encodedKeys[i] = e.Key
+	}
+	keys := newBatchKeys(originalKeys, encodedKeys)
+
+	failed := make([]bool, len(originalKeys))
+	for _, k := range response.GetPutAllResponse().GetFailedKeys() {
+		i, key, sent, err := this.resolveBatchKey(keys, k.Key, "PutAll")
+		if err != nil {
+			return nil, err
+		}
+
+		failure := &KeyFailure{
+			Key: key,
+			Err: newServerError(k.GetError(), putAll, server),
+			key: sent,
+		}
+
+		if i >= 0 {
+			failed[i] = true
+			failure.entry = encodedEntries[i]
+		}
+
+		result.Failures = append(result.Failures, failure)
+	}
+
+	for i, key := range originalKeys {
+		if !failed[i] {
+			result.Succeeded = append(result.Succeeded, key)
+		}
+	}
+
+	return result, nil
+}
+
+// Resubmit sends the keys of a GetAll or PutAll which failed for reasons which may be temporary,
+// as reported by BatchResult.Retryable, again in a single request. The returned BatchResult only
+// describes the resubmitted keys; failures which are not retryable are not included.
+func (this *Protobuf) Resubmit(result *BatchResult) (*BatchResult, error) {
+	return this.ResubmitContext(context.Background(), result)
+}
+
+func (this *Protobuf) ResubmitContext(ctx context.Context, result *BatchResult) (*BatchResult, error) {
+	var keys []interface{}
+	var encodedKeys []*v1.EncodedValue
+	var encodedEntries []*v1.Entry
+
+	for _, f := range result.Failures {
+		if !f.Retryable() || !f.resubmittable(result.Operation) {
+			continue
+		}
+
+		keys = append(keys, f.Key)
+		encodedKeys = append(encodedKeys, f.key)
+		encodedEntries = append(encodedEntries, f.entry)
+	}
+
+	switch result.Operation {
+	case "GetAll":
+		return this.getAllBatch(ctx, result.Region, keys, encodedKeys)
+	case "PutAll":
+		return this.putAllBatch(ctx, result.Region, keys, encodedEntries)
+	}
+
+	return nil, errors.New(fmt.Sprintf("unable to resubmit %s", result.Operation))
+}
@@ -0,0 +1,161 @@
+package connector_test
+
+import (
+	"errors"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batch results", func() {
+
+	var connection *connector.Protobuf
+	var fakeConn *connectorfakes.FakeConn
+
+	keyedError := func(key interface{}, code v1.ErrorCode) *v1.KeyedError {
+		k, err := connector.EncodeValue(key)
+		Expect(err).To(BeNil())
+		return &v1.KeyedError{
+			Key:   k,
+			Error: &v1.Error{ErrorCode: code, Message: code.String()},
+		}
+	}
+
+	entry := func(key, value interface{}) *v1.Entry {
+		k, err := connector.EncodeValue(key)
+		Expect(err).To(BeNil())
+		v, err := connector.EncodeValue(value)
+		Expect(err).To(BeNil())
+		return &v1.Entry{Key: k, Value: v}
+	}
+
+	BeforeEach(func() {
+		connection, _, fakeConn = newFakeConnector()
+	})
+
+	Context("GetAll", func() {
+		BeforeEach(func() {
+			respondWith(fakeConn, &v1.Message{
+				MessageType: &v1.Message_GetAllResponse{
+					GetAllResponse: &v1.GetAllResponse{
+						Entries: []*v1.Entry{entry(1, "one")},
+						Failures: []*v1.KeyedError{
+							keyedError(2, v1.ErrorCode_NO_AVAILABLE_SERVER),
+							keyedError(3, v1.ErrorCode_AUTHORIZATION_FAILED),
+						},
+					},
+				},
+			}, &v1.Message{
+				MessageType: &v1.Message_GetAllResponse{
+					GetAllResponse: &v1.GetAllResponse{
+						Entries: []*v1.Entry{entry(2, "two")},
+					},
+				},
+			})
+		})
+
+		It("reports each key using the caller's keys", func() {
+			result, err := connection.GetAllBatch("foo", []int{1, 2, 3})
+			Expect(err).To(BeNil())
+
+			Expect(result.Succeeded).To(Equal([]interface{}{1}))
+			Expect(result.Values).To(Equal([]interface{}{"one"}))
+			Expect(result.FailedKeys()).To(Equal([]interface{}{2, 3}))
+			Expect(result.Retryable()).To(Equal([]interface{}{2}))
+
+			Expect(result.Failures[0].Code()).To(Equal(v1.ErrorCode_NO_AVAILABLE_SERVER))
+			Expect(errors.Is(result.Failures[1].Err, connector.ErrAuthorizationFailed)).To(BeTrue())
+		})
+
+		It("resubmits retryable keys", func() {
+			result, err := connection.GetAllBatch("foo", []int{1, 2, 3})
+			Expect(err).To(BeNil())
+
+			result, err = connection.Resubmit(result)
+			Expect(err).To(BeNil())
+			Expect(result.Succeeded).To(Equal([]interface{}{2}))
+			Expect(result.Values).To(Equal([]interface{}{"two"}))
+			Expect(result.Failures).To(BeEmpty())
+
+			keys := writtenMessage(fakeConn, 1).GetGetAllRequest().GetKey()
+			Expect(keys).To(HaveLen(1))
+			Expect(keys[0].GetIntResult()).To(Equal(int32(2)))
+		})
+	})
+
+	Context("GetAll with keys which cannot be map keys", func() {
+		BeforeEach(func() {
+			respondWith(fakeConn, &v1.Message{
+				MessageType: &v1.Message_GetAllResponse{
+					GetAllResponse: &v1.GetAllResponse{
+						Entries:  []*v1.Entry{entry([]byte("a"), "one")},
+						Failures: []*v1.KeyedError{keyedError([]byte("b"), v1.ErrorCode_SERVER_ERROR)},
+					},
+				},
+			})
+		})
+
+		It("reports byte slice keys", func() {
+			result, err := connection.GetAllBatch("foo", [][]byte{[]byte("a"), []byte("b")})
+			Expect(err).To(BeNil())
+
+			Expect(result.Succeeded).To(Equal([]interface{}{[]byte("a")}))
+			Expect(result.Values).To(Equal([]interface{}{"one"}))
+			Expect(result.Retryable()).To(Equal([]interface{}{[]byte("b")}))
+		})
+	})
+
+	Context("PutAll", func() {
+		BeforeEach(func() {
+			respondWith(fakeConn, &v1.Message{
+				MessageType: &v1.Message_PutAllResponse{
+					PutAllResponse: &v1.PutAllResponse{
+						FailedKeys: []*v1.KeyedError{
+							keyedError("b", v1.ErrorCode_SERVER_ERROR),
+							keyedError("c", v1.ErrorCode_INVALID_REQUEST),
+						},
+					},
+				},
+			}, &v1.Message{
+				MessageType: &v1.Message_PutAllResponse{
+					PutAllResponse: &v1.PutAllResponse{},
+				},
+			})
+		})
+
+		It("reports the keys which were stored and which failed", func() {
+			result, err := connection.PutAllBatch("foo", map[string]int{"a": 1, "b": 2, "c": 3})
+			Expect(err).To(BeNil())
+
+			Expect(result.Values).To(BeNil())
+			Expect(result.Succeeded).To(Equal([]interface{}{"a"}))
+			Expect(result.FailedKeys()).To(Equal([]interface{}{"b", "c"}))
+			Expect(result.Retryable()).To(Equal([]interface{}{"b"}))
+		})
+
+		It("resubmits the entries of retryable keys", func() {
+			result, err := connection.PutAllBatch("foo", map[string]int{"a": 1, "b": 2, "c": 3})
+			Expect(err).To(BeNil())
+
+			result, err = connection.Resubmit(result)
+			Expect(err).To(BeNil())
+			Expect(result.Succeeded).To(Equal([]interface{}{"b"}))
+			Expect(result.Failures).To(BeEmpty())
+
+			entries := writtenMessage(fakeConn, 1).GetPutAllRequest().GetEntry()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].GetKey().GetStringResult()).To(Equal("b"))
+			Expect(entries[0].GetValue().GetIntResult()).To(Equal(int32(2)))
+		})
+
+		It("does not contact the server when nothing is retryable", func() {
+			result, err := connection.Resubmit(&connector.BatchResult{Operation: "PutAll", Region: "foo"})
+			Expect(err).To(BeNil())
+			Expect(result.Succeeded).To(BeEmpty())
+			Expect(fakeConn.WriteCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package connector_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// benchConn is a connection to an imaginary server which answers every request with the same
+// response. Unlike connectorfakes.FakeConn it does not record calls, so it does not distort
+// allocation counts.
+type benchConn struct {
+	net.Conn
+	response []byte
+	unread   []byte
+}
+
+func newBenchConn(response proto.Message) *benchConn {
+	p := proto.NewBuffer(nil)
+	if err := p.EncodeMessage(response); err != nil {
+		panic(err)
+	}
+
+	return &benchConn{response: p.Bytes()}
+}
+
+func (this *benchConn) Write(b []byte) (int, error) {
+	this.unread = this.response
+	return len(b), nil
+}
+
+func (this *benchConn) Read(b []byte) (int, error) {
+	n := copy(b, this.unread)
+	this.unread = this.unread[n:]
+	return n, nil
+}
+
+func (this *benchConn) Close() error                       { return nil }
+func (this *benchConn) RemoteAddr() net.Addr               { return nil }
+func (this *benchConn) SetDeadline(t time.Time) error      { return nil }
+func (this *benchConn) SetReadDeadline(t time.Time) error  { return nil }
+func (this *benchConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func newBenchConnector(response proto.Message) *connector.Protobuf {
+	pool := connector.NewPool()
+	pool.AddConnection(newBenchConn(response), true)
+
+	return connector.NewConnector(pool)
+}
+
+func BenchmarkGet(b *testing.B) {
+	v, _ := connector.EncodeValue("a value")
+	connection := newBenchConnector(&v1.Message{
+		MessageType: &v1.Message_GetResponse{
+			GetResponse: &v1.GetResponse{Result: v},
+		},
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := connection.Get("region", "key", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPut(b *testing.B) {
+	connection := newBenchConnector(&v1.Message{
+		MessageType: &v1.Message_PutResponse{
+			PutResponse: &v1.PutResponse{},
+		},
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := connection.Put("region", "key", "a value"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package connector_test
 
 import (
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"net"
 	"testing"
 )
 
@@ -11,3 +16,38 @@ func TestConnector(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Protobuf Connector Suite")
 }
+
+// newFakeConnector returns a connector whose pool holds a single authenticated FakeConn, connected
+// to 10.0.0.1:40404
+func newFakeConnector() (*connector.Protobuf, *connector.Pool, *connectorfakes.FakeConn) {
+	fakeConn := new(connectorfakes.FakeConn)
+	fakeConn.RemoteAddrReturns(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 40404})
+	pool := connector.NewPool()
+	pool.AddConnection(fakeConn, true)
+
+	return connector.NewConnector(pool), pool, fakeConn
+}
+
+func writeFakeMessage(m proto.Message, b []byte) (int, error) {
+	p := proto.NewBuffer(nil)
+	p.EncodeMessage(m)
+	n := copy(b, p.Bytes())
+
+	return n, nil
+}
+
+// respondWith answers successive reads from the connection with the given messages
+func respondWith(fakeConn *connectorfakes.FakeConn, messages ...proto.Message) {
+	fakeConn.ReadStub = func(b []byte) (int, error) {
+		m := messages[0]
+		messages = messages[1:]
+		return writeFakeMessage(m, b)
+	}
+}
+
+// writtenMessage decodes the i'th message written to the connection
+func writtenMessage(fakeConn *connectorfakes.FakeConn, i int) *v1.Message {
+	message := &v1.Message{}
+	Expect(proto.NewBuffer(fakeConn.WriteArgsForCall(i)).DecodeMessage(message)).To(Succeed())
+	return message
+}
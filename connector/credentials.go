@@ -0,0 +1,119 @@
+package connector
+
+import (
+	"context"
+	"errors"
+)
+
+// A CredentialsProvider supplies the credentials sent to a server to authenticate a connection.
+// The keys are the security properties expected by the server's SecurityManager, for example
+// security-username and security-password, or a token.
+//
+// The provider is consulted every time a new connection is authenticated, so credentials which
+// are rotated are picked up without recreating the pool. When a server rejects the credentials of
+// a connection, the connection is discarded and the rejected operation, which the server did not
+// perform, is sent again on a new connection authenticated with the provider's current
+// credentials. It may be called from several goroutines at once.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (map[string]string, error)
+}
+
+// CredentialsProviderFunc allows an ordinary function to be used as a CredentialsProvider.
+type CredentialsProviderFunc func(ctx context.Context) (map[string]string, error)
+
+func (f CredentialsProviderFunc) Credentials(ctx context.Context) (map[string]string, error) {
+	return f(ctx)
+}
+
+// UsernamePassword returns a CredentialsProvider which always supplies the given username and
+// password.
+func UsernamePassword(username, password string) CredentialsProvider {
+	return CredentialsProviderFunc(func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{
+			"security-username": username,
+			"security-password": password,
+		}, nil
+	})
+}
+
+// ErrUnnamedIdentity is returned when a connection is requested for an Identity without a Name.
+var ErrUnnamedIdentity = errors.New("identity has no name")
+
+// ErrIdentityWithoutCredentials is returned when a connection is requested for an Identity without
+// Credentials.
+var ErrIdentityWithoutCredentials = errors.New("identity has no credentials")
+
+// An Identity is a principal on whose behalf operations are performed, allowing a single pool to
+// be shared by several users. Connections are authenticated as one identity and are only used for
+// operations performed as that identity. Identities with the same name share connections, so each
+// distinct principal must be given a distinct name. An empty name is rejected with
+// ErrUnnamedIdentity, as it would be indistinguishable from the pool's own credentials, and
+// missing Credentials with ErrIdentityWithoutCredentials, as the connection would not be
+// authenticated at all.
+type Identity struct {
+	Name        string
+	Credentials CredentialsProvider
+}
+
+// name returns the name which connections for the identity are tagged with. Connections using the
+// pool's own credentials have an empty name, which is why identities must not.
+func (this *Identity) name() string {
+	if this == nil {
+		return ""
+	}
+
+	return this.Name
+}
+
+// SetCredentialsProvider enables authentication, using the given provider to obtain credentials.
+// Connections which have already been authenticated keep their credentials until the server
+// rejects them, at which point they are replaced.
+func (this *Pool) SetCredentialsProvider(provider CredentialsProvider) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.credentials = provider
+}
+
+// AddCredentials enables authentication with a fixed username and password.
+func (this *Pool) AddCredentials(username, password string) {
+	this.SetCredentialsProvider(UsernamePassword(username, password))
+}
+
+// credentialsProvider returns the provider of credentials for the given identity, or for the pool
+// if the identity is nil.
+func (this *Pool) credentialsProvider(identity *Identity) CredentialsProvider {
+	if identity != nil {
+		return identity.Credentials
+	}
+
+	this.RLock()
+	defer this.RUnlock()
+
+	return this.credentials
+}
+
+// authenticateConnection obtains the current credentials, if there is a provider, and
+// authenticates the connection with them. The pool's value format is selected at the same time.
+// The connection must be reserved by the caller.
+// MUST NOT hold the pool lock when calling
+func (this *Pool) authenticateConnection(ctx context.Context, gConn *GeodeConnection, provider CredentialsProvider) (err error) {
+	ctx, span := this.startSpan(ctx, "Authenticate")
+	defer func() { finishSpan(span, err) }()
+
+	var creds map[string]string
+
+	if provider != nil {
+		if creds, err = provider.Credentials(ctx); err != nil {
+			return err
+		}
+	}
+
+	return gConn.authenticate(creds, this.valueFormat())
+}
+
+// isAuthenticationError returns true if the server rejected a request because the connection is
+// not, or is no longer, authenticated.
+func isAuthenticationError(err error) bool {
+	return errors.Is(err, ErrAuthenticationRequired) || errors.Is(err, ErrAuthenticationFailed)
+}
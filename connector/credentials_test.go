@@ -0,0 +1,310 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	"github.com/gemfire/geode-go-client/protobuf"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials", func() {
+
+	var connection *connector.Protobuf
+	var fakeConn *connectorfakes.FakeConn
+	var pool *connector.Pool
+	var token string
+	var calls int
+
+	authenticated := &v1.Message{
+		MessageType: &v1.Message_HandshakeResponse{
+			HandshakeResponse: &v1.HandshakeResponse{
+				Authenticated: true,
+			},
+		},
+	}
+
+	putResponse := &v1.Message{
+		MessageType: &v1.Message_PutResponse{
+			PutResponse: &v1.PutResponse{},
+		},
+	}
+
+	errorResponse := func(code v1.ErrorCode) *v1.Message {
+		return &v1.Message{
+			MessageType: &v1.Message_ErrorResponse{
+				ErrorResponse: &v1.ErrorResponse{
+					Error: &v1.Error{
+						ErrorCode: code,
+						Message:   "credentials expired",
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		connection, pool, fakeConn = newFakeConnector()
+
+		token = "first"
+		calls = 0
+		pool.SetCredentialsProvider(connector.CredentialsProviderFunc(func(ctx context.Context) (map[string]string, error) {
+			calls += 1
+			return map[string]string{"security-token": token}, nil
+		}))
+	})
+
+	It("sends the credentials supplied by the provider", func() {
+		respondWith(fakeConn, authenticated, putResponse)
+
+		Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+		Expect(calls).To(Equal(1))
+		Expect(writtenMessage(fakeConn, 0).GetHandshakeRequest().GetCredentials()).To(Equal(map[string]string{"security-token": "first"}))
+	})
+
+	It("only authenticates a connection once", func() {
+		respondWith(fakeConn, authenticated, putResponse, putResponse)
+
+		Expect(connection.Put("foo", "a", 1)).To(Succeed())
+		Expect(connection.Put("foo", "b", 2)).To(Succeed())
+
+		Expect(calls).To(Equal(1))
+		Expect(fakeConn.WriteCallCount()).To(Equal(3))
+	})
+
+	It("sends the username and password given to AddCredentials", func() {
+		pool.AddCredentials("jbloggs", "t0p53cr3t")
+		respondWith(fakeConn, authenticated, putResponse)
+
+		Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+		Expect(writtenMessage(fakeConn, 0).GetHandshakeRequest().GetCredentials()).To(Equal(map[string]string{
+			"security-username": "jbloggs",
+			"security-password": "t0p53cr3t",
+		}))
+	})
+
+	It("returns an error from the provider", func() {
+		pool.SetCredentialsProvider(connector.CredentialsProviderFunc(func(ctx context.Context) (map[string]string, error) {
+			return nil, errors.New("vault unavailable")
+		}))
+
+		err := connection.Put("foo", "a", 1)
+		Expect(err).To(MatchError("vault unavailable"))
+		Expect(fakeConn.WriteCallCount()).To(Equal(0))
+	})
+
+	Context("when the server rejects the credentials", func() {
+		var conns []*connectorfakes.FakeConn
+		var scripts [][]proto.Message
+
+		// handshakeRequest returns the HandshakeRequest sent by the nth connection
+		handshakeRequest := func(n int) *v1.HandshakeRequest {
+			return writtenMessage(conns[n], 1).GetHandshakeRequest()
+		}
+
+		BeforeEach(func() {
+			conns = nil
+			scripts = nil
+
+			provider := connector.CredentialsProviderFunc(func(ctx context.Context) (map[string]string, error) {
+				calls += 1
+				return map[string]string{"security-token": token}, nil
+			})
+
+			pool = connector.NewPool()
+			pool.SetDialer(connector.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				conn := new(connectorfakes.FakeConn)
+				respondWith(conn, append([]proto.Message{
+					&org_apache_geode_internal_protocol_protobuf.VersionAcknowledgement{VersionAccepted: true},
+				}, scripts[len(conns)]...)...)
+				conns = append(conns, conn)
+				return conn, nil
+			}))
+			pool.AddServer("server", 1)
+			pool.SetCredentialsProvider(provider)
+			connection = connector.NewConnector(pool)
+		})
+
+		for _, code := range []v1.ErrorCode{v1.ErrorCode_AUTHENTICATION_REQUIRED, v1.ErrorCode_AUTHENTICATION_FAILED} {
+			code := code
+
+			It("replaces the connection with one authenticated with fresh credentials on "+code.String(), func() {
+				scripts = [][]proto.Message{
+					{authenticated, putResponse, errorResponse(code)},
+					{authenticated, putResponse},
+				}
+
+				Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+				token = "rotated"
+				Expect(connection.Put("foo", "b", 2)).To(Succeed())
+
+				Expect(calls).To(Equal(2))
+				Expect(conns).To(HaveLen(2))
+				Expect(conns[0].CloseCallCount()).To(Equal(1))
+				Expect(conns[0].WriteCallCount()).To(Equal(4))
+				Expect(handshakeRequest(1).GetCredentials()).To(Equal(map[string]string{"security-token": "rotated"}))
+
+				Expect(writtenMessage(conns[1], 2).GetPutRequest()).ToNot(BeNil())
+			})
+		}
+
+		It("resends operations which are not idempotent", func() {
+			scripts = [][]proto.Message{
+				{authenticated, errorResponse(v1.ErrorCode_AUTHENTICATION_REQUIRED)},
+				{authenticated, &v1.Message{
+					MessageType: &v1.Message_PutIfAbsentResponse{
+						PutIfAbsentResponse: &v1.PutIfAbsentResponse{},
+					},
+				}},
+			}
+
+			Expect(connection.PutIfAbsent("foo", "a", 1)).To(Succeed())
+			Expect(conns).To(HaveLen(2))
+			Expect(conns[0].CloseCallCount()).To(Equal(1))
+			Expect(writtenMessage(conns[1], 2).GetPutIfAbsentRequest()).ToNot(BeNil())
+		})
+
+		It("only replaces the connection once per operation", func() {
+			scripts = [][]proto.Message{
+				{authenticated, errorResponse(v1.ErrorCode_AUTHENTICATION_FAILED)},
+				{authenticated, errorResponse(v1.ErrorCode_AUTHENTICATION_FAILED)},
+			}
+
+			err := connection.Put("foo", "a", 1)
+			Expect(err).To(MatchError("credentials expired (11)"))
+			Expect(calls).To(Equal(2))
+			Expect(conns).To(HaveLen(2))
+		})
+
+		It("returns an error when the new connection is not authenticated", func() {
+			scripts = [][]proto.Message{
+				{authenticated, errorResponse(v1.ErrorCode_AUTHENTICATION_REQUIRED)},
+				{&v1.Message{
+					MessageType: &v1.Message_HandshakeResponse{
+						HandshakeResponse: &v1.HandshakeResponse{},
+					},
+				}},
+			}
+
+			err := connection.Put("foo", "a", 1)
+			Expect(err).To(BeAssignableToTypeOf(connector.AuthenticationError("")))
+			Expect(conns).To(HaveLen(2))
+			Expect(conns[0].CloseCallCount()).To(Equal(1))
+			Expect(conns[1].CloseCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("with identities", func() {
+		var conns []*connectorfakes.FakeConn
+		var alice, bob *connector.Identity
+
+		identity := func(name string) *connector.Identity {
+			return &connector.Identity{
+				Name:        name,
+				Credentials: connector.UsernamePassword(name, "secret"),
+			}
+		}
+
+		// authenticatedAs returns the username sent by the nth connection
+		authenticatedAs := func(n int) string {
+			return writtenMessage(conns[n], 1).GetHandshakeRequest().GetCredentials()["security-username"]
+		}
+
+		BeforeEach(func() {
+			conns = nil
+			alice = identity("alice")
+			bob = identity("bob")
+
+			pool = connector.NewPool()
+			pool.SetDialer(connector.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				conn := new(connectorfakes.FakeConn)
+				replies := []proto.Message{
+					&org_apache_geode_internal_protocol_protobuf.VersionAcknowledgement{VersionAccepted: true},
+					authenticated,
+				}
+				conn.ReadStub = func(b []byte) (int, error) {
+					if len(replies) == 0 {
+						return writeFakeMessage(putResponse, b)
+					}
+					m := replies[0]
+					replies = replies[1:]
+					return writeFakeMessage(m, b)
+				}
+				conns = append(conns, conn)
+				return conn, nil
+			}))
+			pool.AddServer("server", 1)
+			pool.AddCredentials("pool", "secret")
+			connection = connector.NewConnector(pool)
+		})
+
+		It("only uses connections authenticated as the same identity", func() {
+			Expect(connection.WithIdentity(alice).Put("foo", "a", 1)).To(Succeed())
+			Expect(connection.WithIdentity(bob).Put("foo", "b", 2)).To(Succeed())
+			Expect(connection.WithIdentity(alice).Put("foo", "c", 3)).To(Succeed())
+
+			Expect(conns).To(HaveLen(2))
+			Expect(authenticatedAs(0)).To(Equal("alice"))
+			Expect(authenticatedAs(1)).To(Equal("bob"))
+			Expect(conns[0].WriteCallCount()).To(Equal(4))
+			Expect(conns[1].WriteCallCount()).To(Equal(3))
+		})
+
+		It("shares connections between identities with the same name", func() {
+			Expect(connection.WithIdentity(alice).Put("foo", "a", 1)).To(Succeed())
+			Expect(connection.WithIdentity(identity("alice")).Put("foo", "b", 2)).To(Succeed())
+
+			Expect(conns).To(HaveLen(1))
+		})
+
+		It("uses the pool's credentials without an identity", func() {
+			Expect(connection.WithIdentity(alice).Put("foo", "a", 1)).To(Succeed())
+			Expect(connection.Put("foo", "b", 2)).To(Succeed())
+
+			Expect(conns).To(HaveLen(2))
+			Expect(authenticatedAs(1)).To(Equal("pool"))
+		})
+
+		It("rejects an identity without a name", func() {
+			Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+			unnamed := &connector.Identity{Credentials: connector.UsernamePassword("mallory", "secret")}
+			err := connection.WithIdentity(unnamed).Put("foo", "b", 2)
+			Expect(err).To(Equal(connector.ErrUnnamedIdentity))
+
+			Expect(conns).To(HaveLen(1))
+			Expect(conns[0].WriteCallCount()).To(Equal(3))
+		})
+
+		It("rejects an identity without credentials", func() {
+			Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+			anonymous := &connector.Identity{Name: "mallory"}
+			err := connection.WithIdentity(anonymous).Put("foo", "b", 2)
+			Expect(err).To(Equal(connector.ErrIdentityWithoutCredentials))
+
+			Expect(conns).To(HaveLen(1))
+			Expect(conns[0].WriteCallCount()).To(Equal(3))
+		})
+
+		It("replaces idle connections for other identities when the pool is full", func() {
+			pool.SetMaxConnections(1)
+
+			Expect(connection.WithIdentity(alice).Put("foo", "a", 1)).To(Succeed())
+			Expect(connection.WithIdentity(bob).Put("foo", "b", 2)).To(Succeed())
+
+			Expect(conns).To(HaveLen(2))
+			Expect(conns[0].CloseCallCount()).To(Equal(1))
+			Expect(authenticatedAs(1)).To(Equal("bob"))
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package connector
+
+import (
+	"sync"
+
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+)
+
+// Gets and Puts are by far the most frequent operations, so their request messages are reused
+// rather than allocated for every operation. A request is released once its operation has
+// completed and must not be used afterwards. Interceptors may have modified the message, so it is
+// rebuilt in full before it is reused.
+
+// An encodedValue is a reusable EncodedValue which does not allocate for strings, the most common
+// type of key.
+type encodedValue struct {
+	value       v1.EncodedValue
+	stringValue v1.EncodedValue_StringResult
+}
+
+func (this *encodedValue) set(val interface{}, serializer ValueSerializer) (*v1.EncodedValue, error) {
+	if s, ok := val.(string); ok {
+		this.stringValue.StringResult = s
+		this.value.Value = &this.stringValue
+	} else if err := encodeValue(&this.value, val, serializer); err != nil {
+		return nil, err
+	}
+
+	return &this.value, nil
+}
+
+func (this *encodedValue) clear() {
+	this.value = v1.EncodedValue{}
+	this.stringValue = v1.EncodedValue_StringResult{}
+}
+
+type getRequest struct {
+	message v1.Message
+	wrapper v1.Message_GetRequest
+	request v1.GetRequest
+	key     encodedValue
+}
+
+var getRequests = sync.Pool{
+	New: func() interface{} {
+		r := &getRequest{}
+		r.reset()
+		return r
+	},
+}
+
+func newGetRequest(region string, key interface{}, serializer ValueSerializer) (*getRequest, error) {
+	r := getRequests.Get().(*getRequest)
+
+	k, err := r.key.set(key, serializer)
+	if err != nil {
+		r.release()
+		return nil, err
+	}
+
+	r.request.RegionName = region
+	r.request.Key = k
+
+	return r, nil
+}
+
+// reset links the message to its request, which holds no values.
+func (this *getRequest) reset() {
+	this.request = v1.GetRequest{}
+	this.wrapper = v1.Message_GetRequest{GetRequest: &this.request}
+	this.message = v1.Message{MessageType: &this.wrapper}
+	this.key.clear()
+}
+
+func (this *getRequest) release() {
+	this.reset()
+	getRequests.Put(this)
+}
+
+type putRequest struct {
+	message v1.Message
+	wrapper v1.Message_PutRequest
+	request v1.PutRequest
+	entry   v1.Entry
+	key     encodedValue
+	value   encodedValue
+}
+
+var putRequests = sync.Pool{
+	New: func() interface{} {
+		r := &putRequest{}
+		r.reset()
+		return r
+	},
+}
+
+func newPutRequest(region string, key, value interface{}, serializer ValueSerializer) (*putRequest, error) {
+	r := putRequests.Get().(*putRequest)
+
+	k, err := r.key.set(key, serializer)
+	if err != nil {
+		r.release()
+		return nil, err
+	}
+
+	v, err := r.value.set(value, serializer)
+	if err != nil {
+		r.release()
+		return nil, err
+	}
+
+	r.entry.Key = k
+	r.entry.Value = v
+	r.request.RegionName = region
+	r.request.Entry = &r.entry
+
+	return r, nil
+}
+
+// reset links the message to its request, which holds no values.
+func (this *putRequest) reset() {
+	this.request = v1.PutRequest{}
+	this.wrapper = v1.Message_PutRequest{PutRequest: &this.request}
+	this.message = v1.Message{MessageType: &this.wrapper}
+	this.entry = v1.Entry{}
+	this.key.clear()
+	this.value.clear()
+}
+
+func (this *putRequest) release() {
+	this.reset()
+	putRequests.Put(this)
+}
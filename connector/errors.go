@@ -0,0 +1,71 @@
+package connector
+
+import (
+	"fmt"
+
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+)
+
+// A ServerError is an error returned by a server, either for a whole request or for a single key
+// of a GetAll or PutAll. Use errors.Is with one of the Err sentinels below to test its code, or
+// errors.As to obtain the details.
+type ServerError struct {
+	Code    v1.ErrorCode
+	Message string
+
+	// Operation is the operation which failed, such as "Get".
+	Operation string
+
+	// Region is the region the operation applied to, if any.
+	Region string
+
+	// Server is the address of the server which returned the error.
+	Server string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// Is reports whether the target is the sentinel for the error's code.
+func (e *ServerError) Is(target error) bool {
+	c, ok := target.(codeSentinel)
+	return ok && v1.ErrorCode(c) == e.Code
+}
+
+// A codeSentinel matches every ServerError with the same code.
+type codeSentinel v1.ErrorCode
+
+func (e codeSentinel) Error() string {
+	return v1.ErrorCode(e).String()
+}
+
+// Sentinels for each of the codes returned by servers, for use with errors.Is.
+var (
+	ErrAuthenticationRequired     error = codeSentinel(v1.ErrorCode_AUTHENTICATION_REQUIRED)
+	ErrAuthenticationFailed       error = codeSentinel(v1.ErrorCode_AUTHENTICATION_FAILED)
+	ErrAlreadyAuthenticated       error = codeSentinel(v1.ErrorCode_ALREADY_AUTHENTICATED)
+	ErrAuthenticationNotSupported error = codeSentinel(v1.ErrorCode_AUTHENTICATION_NOT_SUPPORTED)
+	ErrAuthorizationFailed        error = codeSentinel(v1.ErrorCode_AUTHORIZATION_FAILED)
+	ErrInvalidRequest             error = codeSentinel(v1.ErrorCode_INVALID_REQUEST)
+	ErrUnsupportedOperation       error = codeSentinel(v1.ErrorCode_UNSUPPORTED_OPERATION)
+	ErrServerError                error = codeSentinel(v1.ErrorCode_SERVER_ERROR)
+	ErrNoAvailableServer          error = codeSentinel(v1.ErrorCode_NO_AVAILABLE_SERVER)
+)
+
+// ErrCode returns the sentinel for any ErrorCode, including codes added to the protocol after
+// this client was written.
+func ErrCode(code v1.ErrorCode) error {
+	return codeSentinel(code)
+}
+
+// newServerError creates a ServerError from an Error sent by a server in response to a request.
+func newServerError(e *v1.Error, request *v1.Message, server string) *ServerError {
+	return &ServerError{
+		Code:      e.GetErrorCode(),
+		Message:   e.GetMessage(),
+		Operation: operationName(request),
+		Region:    operationRegion(request),
+		Server:    server,
+	}
+}
@@ -0,0 +1,155 @@
+package connector_test
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server errors", func() {
+
+	var connection *connector.Protobuf
+	var fakeConn *connectorfakes.FakeConn
+
+	errorResponse := func(code v1.ErrorCode, message string) *v1.Message {
+		return &v1.Message{
+			MessageType: &v1.Message_ErrorResponse{
+				ErrorResponse: &v1.ErrorResponse{
+					Error: &v1.Error{
+						ErrorCode: code,
+						Message:   message,
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		connection, _, fakeConn = newFakeConnector()
+	})
+
+	It("describes the failed operation", func() {
+		respondWith(fakeConn, errorResponse(v1.ErrorCode_AUTHORIZATION_FAILED, "not authorized"))
+
+		err := connection.Put("foo", "a", 1)
+		Expect(err).To(MatchError("not authorized (20)"))
+
+		var serverErr *connector.ServerError
+		Expect(errors.As(err, &serverErr)).To(BeTrue())
+		Expect(*serverErr).To(Equal(connector.ServerError{
+			Code:      v1.ErrorCode_AUTHORIZATION_FAILED,
+			Message:   "not authorized",
+			Operation: "Put",
+			Region:    "foo",
+			Server:    "10.0.0.1:40404",
+		}))
+	})
+
+	It("matches the sentinel for its code", func() {
+		respondWith(fakeConn, errorResponse(v1.ErrorCode_NO_AVAILABLE_SERVER, "no server"))
+
+		_, err := connection.Get("foo", "a", nil)
+		Expect(errors.Is(err, connector.ErrNoAvailableServer)).To(BeTrue())
+		Expect(errors.Is(err, connector.ErrCode(v1.ErrorCode_NO_AVAILABLE_SERVER))).To(BeTrue())
+		Expect(errors.Is(err, connector.ErrAuthorizationFailed)).To(BeFalse())
+	})
+
+	It("returns typed errors for GetAll failures", func() {
+		key, _ := connector.EncodeValue("a")
+		respondWith(fakeConn, &v1.Message{
+			MessageType: &v1.Message_GetAllResponse{
+				GetAllResponse: &v1.GetAllResponse{
+					Failures: []*v1.KeyedError{
+						{
+							Key: key,
+							Error: &v1.Error{
+								ErrorCode: v1.ErrorCode_INVALID_REQUEST,
+								Message:   "bad key",
+							},
+						},
+					},
+				},
+			},
+		})
+
+		_, failures, err := connection.GetAll("foo", []string{"a"})
+		Expect(err).To(BeNil())
+		Expect(errors.Is(failures["a"], connector.ErrInvalidRequest)).To(BeTrue())
+
+		var serverErr *connector.ServerError
+		Expect(errors.As(failures["a"], &serverErr)).To(BeTrue())
+		Expect(serverErr.Operation).To(Equal("GetAll"))
+		Expect(serverErr.Region).To(Equal("foo"))
+		Expect(serverErr.Server).To(Equal("10.0.0.1:40404"))
+	})
+
+	It("returns typed errors for PutAll failures", func() {
+		key, _ := connector.EncodeValue("a")
+		respondWith(fakeConn, &v1.Message{
+			MessageType: &v1.Message_PutAllResponse{
+				PutAllResponse: &v1.PutAllResponse{
+					FailedKeys: []*v1.KeyedError{
+						{
+							Key: key,
+							Error: &v1.Error{
+								ErrorCode: v1.ErrorCode_SERVER_ERROR,
+								Message:   "failed",
+							},
+						},
+					},
+				},
+			},
+		})
+
+		failures, err := connection.PutAll("foo", map[string]int{"a": 1})
+		Expect(err).To(BeNil())
+		Expect(errors.Is(failures["a"], connector.ErrServerError)).To(BeTrue())
+	})
+
+	It("describes the server of errors from concurrent operations", func() {
+		listener := startFakeGeodeServer(func(*v1.Message) *v1.Message {
+			return errorResponse(v1.ErrorCode_SERVER_ERROR, "failed")
+		})
+		defer listener.Close()
+
+		host, port := hostAndPort(listener)
+		pool := connector.NewPool()
+		pool.SetMaxConnections(4)
+		pool.AddServer(host, port)
+		defer pool.Close()
+		connection := connector.NewConnector(pool)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 16*10)
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					_, err := connection.Size("foo")
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			var serverErr *connector.ServerError
+			Expect(errors.As(err, &serverErr)).To(BeTrue())
+			Expect(serverErr.Server).To(Equal(listener.Addr().String()))
+		}
+	})
+
+	It("keeps the server's error inside an UnsupportedOperationError", func() {
+		respondWith(fakeConn, errorResponse(v1.ErrorCode_UNSUPPORTED_OPERATION, "unsupported"))
+
+		err := connection.PutIfAbsent("foo", "a", 1)
+		Expect(errors.Is(err, connector.ErrUnsupportedOperation)).To(BeTrue())
+	})
+})
@@ -0,0 +1,153 @@
+package connector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// The largest message accepted from a server unless configured otherwise
+const defaultMaxMessageSize = 64 * 1024 * 1024
+
+const readBufferSize = 4096
+
+// Message buffers larger than this are not kept for reuse once the message has been decoded
+const maxRetainedBufferSize = 1024 * 1024
+
+// Room reserved at the start of each write buffer for the length prefix of the message
+const maxLengthPrefix = binary.MaxVarintLen64
+
+// Buffers used to encode messages, so that each write does not allocate a new one
+var writeBuffers = sync.Pool{
+	New: func() interface{} {
+		return proto.NewBuffer(make([]byte, 0, readBufferSize))
+	},
+}
+
+// A FrameTooLargeError is returned when a server sends a message larger than the maximum message
+// size. The connection cannot be used afterwards.
+type FrameTooLargeError struct {
+	Size uint64
+	Max  int
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("message of %d bytes exceeds the maximum size of %d bytes", e.Size, e.Max)
+}
+
+// A frameReader reads varint length-delimited messages from a connection. Reads are buffered, so
+// the same frameReader must be used for every message read from a connection; bytes belonging to
+// the next message are kept until that message is read.
+type frameReader struct {
+	reader  *bufio.Reader
+	maxSize int
+	buf     []byte
+}
+
+func newFrameReader(c net.Conn, maxSize int) *frameReader {
+	if maxSize <= 0 {
+		maxSize = defaultMaxMessageSize
+	}
+
+	return &frameReader{
+		reader:  bufio.NewReaderSize(c, readBufferSize),
+		maxSize: maxSize,
+	}
+}
+
+// readFrame returns the next message, without its length prefix. The returned slice is only valid
+// until the next call to readFrame.
+func (this *frameReader) readFrame() ([]byte, error) {
+	length, err := binary.ReadUvarint(this.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if length > uint64(this.maxSize) {
+		return nil, &FrameTooLargeError{
+			Size: length,
+			Max:  this.maxSize,
+		}
+	}
+
+	data := this.buf
+	if uint64(cap(data)) < length {
+		data = make([]byte, length)
+	}
+	data = data[:length]
+
+	if _, err := io.ReadFull(this.reader, data); err != nil {
+		return nil, err
+	}
+
+	if cap(data) <= maxRetainedBufferSize {
+		this.buf = data
+	} else {
+		this.buf = nil
+	}
+
+	return data, nil
+}
+
+// buffered returns the number of bytes which have been read from the connection but not yet
+// consumed.
+func (this *frameReader) buffered() int {
+	return this.reader.Buffered()
+}
+
+// peek waits for the next byte from the connection, without consuming it.
+func (this *frameReader) peek() error {
+	_, err := this.reader.Peek(1)
+
+	return err
+}
+
+// writeMessage writes a length-delimited message to a connection and returns the number of bytes
+// written. The message is encoded after space reserved for its length prefix, so that it can be
+// written with a single call without being copied or sized separately.
+func writeMessage(connection net.Conn, message proto.Message) (int, error) {
+	p := writeBuffers.Get().(*proto.Buffer)
+	defer func() {
+		if cap(p.Bytes()) <= maxRetainedBufferSize {
+			writeBuffers.Put(p)
+		}
+	}()
+
+	p.SetBuf(append(p.Bytes()[:0], make([]byte, maxLengthPrefix)...))
+	if err := p.Marshal(message); err != nil {
+		return 0, err
+	}
+
+	data := p.Bytes()
+	size := uint64(len(data) - maxLengthPrefix)
+	start := maxLengthPrefix - varintLength(size)
+	binary.PutUvarint(data[start:], size)
+
+	n, err := connection.Write(data[start:])
+	if err != nil {
+		switch nerr := err.(type) {
+		case *net.OpError:
+			if nerr.Op == "write" {
+				return n, &RetryableError{Err: err}
+			}
+		}
+		return n, err
+	}
+
+	return n, nil
+}
+
+func varintLength(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n += 1
+	}
+
+	return n
+}
@@ -0,0 +1,116 @@
+package connector_test
+
+import (
+	"errors"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Framing", func() {
+
+	var connection *connector.Protobuf
+	var fakeConn *connectorfakes.FakeConn
+	var pool *connector.Pool
+
+	getResponse := func(value interface{}) []byte {
+		v, err := connector.EncodeValue(value)
+		Expect(err).To(BeNil())
+
+		p := proto.NewBuffer(nil)
+		Expect(p.EncodeMessage(&v1.Message{
+			MessageType: &v1.Message_GetResponse{
+				GetResponse: &v1.GetResponse{
+					Result: v,
+				},
+			},
+		})).To(Succeed())
+
+		return p.Bytes()
+	}
+
+	// serve returns the given data from successive reads, at most chunk bytes at a time
+	serve := func(data []byte, chunk int) {
+		fakeConn.ReadStub = func(b []byte) (int, error) {
+			n := len(data)
+			if n > chunk {
+				n = chunk
+			}
+			n = copy(b, data[:n])
+			data = data[n:]
+			return n, nil
+		}
+	}
+
+	BeforeEach(func() {
+		fakeConn = new(connectorfakes.FakeConn)
+		pool = connector.NewPool()
+	})
+
+	JustBeforeEach(func() {
+		pool.AddConnection(fakeConn, true)
+		connection = connector.NewConnector(pool)
+	})
+
+	It("keeps bytes belonging to the next message", func() {
+		serve(append(getResponse("first"), getResponse("second")...), 4096)
+
+		r, err := connection.Get("foo", "a", nil)
+		Expect(err).To(BeNil())
+		Expect(r).To(Equal("first"))
+
+		r, err = connection.Get("foo", "b", nil)
+		Expect(err).To(BeNil())
+		Expect(r).To(Equal("second"))
+
+		Expect(fakeConn.ReadCallCount()).To(Equal(1))
+	})
+
+	It("reads messages which arrive in pieces", func() {
+		serve(getResponse("a value which arrives a few bytes at a time"), 3)
+
+		r, err := connection.Get("foo", "a", nil)
+		Expect(err).To(BeNil())
+		Expect(r).To(Equal("a value which arrives a few bytes at a time"))
+	})
+
+	It("reads messages larger than the read buffer", func() {
+		large := make([]byte, 100*1024)
+		for i := range large {
+			large[i] = byte(i)
+		}
+		serve(getResponse(large), 4096)
+
+		r, err := connection.Get("foo", "a", nil)
+		Expect(err).To(BeNil())
+		Expect(r).To(Equal(large))
+	})
+
+	Context("with a maximum message size", func() {
+		BeforeEach(func() {
+			pool.SetMaxMessageSize(32)
+		})
+
+		It("rejects larger messages", func() {
+			serve(getResponse(make([]byte, 64)), 4096)
+
+			_, err := connection.Get("foo", "a", nil)
+
+			var frameErr *connector.FrameTooLargeError
+			Expect(errors.As(err, &frameErr)).To(BeTrue())
+			Expect(frameErr.Max).To(Equal(32))
+			Expect(fakeConn.CloseCallCount()).To(Equal(1))
+		})
+
+		It("rejects a corrupt length without allocating it", func() {
+			serve(proto.EncodeVarint(1<<40), 4096)
+
+			_, err := connection.Get("foo", "a", nil)
+			Expect(err).To(MatchError("message of 1099511627776 bytes exceeds the maximum size of 32 bytes"))
+		})
+	})
+})
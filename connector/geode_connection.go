@@ -1,49 +1,209 @@
 package connector
 
 import (
+	"context"
 	"net"
 	"github.com/gemfire/geode-go-client/protobuf"
 	"errors"
 	"fmt"
 	"github.com/golang/protobuf/proto"
 	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"time"
 )
 
+// How long to wait for the server to acknowledge a DisconnectClientRequest
+const disconnectTimeout = 1 * time.Second
+
+// How long to wait for data when checking whether the server has closed a connection
+const peekTimeout = 1 * time.Millisecond
+
 type GeodeConnection struct {
 	rawConn            net.Conn
+	host               string
+	port               int
+	serverGroup        string
 	handshakeDone      bool
 	authenticationDone bool
 	inUse              bool
+	acquired           bool
+	createdAt          time.Time
+	lastUsed           time.Time
+	lastChecked        time.Time
+	deadlineSet        bool
+	maxMessageSize     int
+	frames             *frameReader
+	address            string
+	identity           string
+	serverVersion      ProtocolVersion
+	metrics            Metrics
 }
 
 func (this *GeodeConnection) GetRawConnection() net.Conn {
 	return this.rawConn
 }
 
+// usableFor returns true if the connection may be used for operations against the given server
+// group, or any group if it is empty, performed as the given identity.
+func (this *GeodeConnection) usableFor(group string, identity *Identity) bool {
+	return (group == "" || this.serverGroup == group) && this.identity == identity.name()
+}
+
+// serverAddress returns the address of the server this connection is to, for use in errors.
+func (this *GeodeConnection) serverAddress() string {
+	if this.address == "" {
+		return "unknown"
+	}
+
+	return this.address
+}
+
+// connectionAddress returns the address of the server a connection is to. It is computed once,
+// when the connection is created, as connections are used by several goroutines.
+func connectionAddress(c net.Conn, host string, port int) string {
+	if host != "" {
+		return serverKey(host, port)
+	}
+
+	if addr := c.RemoteAddr(); addr != nil {
+		return addr.String()
+	}
+
+	return "unknown"
+}
+
+// send writes a message to the server.
+func (this *GeodeConnection) send(message proto.Message) error {
+	n, err := writeMessage(this.rawConn, message)
+	if n > 0 && this.metrics != nil {
+		this.metrics.BytesSent(n)
+	}
+
+	return err
+}
+
+// receive reads the next message from the server. The returned slice is only valid until the
+// next message is read.
+func (this *GeodeConnection) receive() ([]byte, error) {
+	data, err := this.frameReader().readFrame()
+	if err == nil && this.metrics != nil {
+		this.metrics.BytesReceived(varintLength(uint64(len(data))) + len(data))
+	}
+
+	return data, err
+}
+
+// frameReader returns the reader used for every message received on this connection.
+func (this *GeodeConnection) frameReader() *frameReader {
+	if this.frames == nil {
+		this.frames = newFrameReader(this.rawConn, this.maxMessageSize)
+	}
+
+	return this.frames
+}
+
+// watchContext applies the deadline of the context, if any, to the underlying connection and
+// interrupts any blocked reads or writes when the context is cancelled. The returned function
+// must be called once the operation using the connection has completed.
+func (this *GeodeConnection) watchContext(ctx context.Context) func() {
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline || this.deadlineSet {
+		// A zero deadline clears any previous one
+		_ = this.rawConn.SetDeadline(deadline)
+		this.deadlineSet = hasDeadline
+	}
+
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		select {
+		case <-ctx.Done():
+			_ = this.rawConn.SetDeadline(time.Unix(1, 0))
+			this.deadlineSet = true
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// isClosed is a cheap check for a connection which the server has closed or reset. It reads with
+// a very short deadline; an idle connection has nothing to read, so a timeout means that the
+// connection is still open. Connections which were dropped silently, for example by a firewall,
+// are not detected; use ping for that.
+func (this *GeodeConnection) isClosed() bool {
+	frames := this.frameReader()
+	if frames.buffered() > 0 {
+		// Unsolicited data means that the connection can no longer be trusted
+		return true
+	}
+
+	_ = this.rawConn.SetReadDeadline(time.Now().Add(peekTimeout))
+	err := frames.peek()
+	_ = this.rawConn.SetReadDeadline(time.Time{})
+
+	if err == nil {
+		return true
+	}
+
+	nerr, ok := err.(net.Error)
+
+	return !ok || !nerr.Timeout()
+}
+
+// ping sends a cheap request to the server and waits for its response. Any response, even an
+// error, shows that the server is still there.
+func (this *GeodeConnection) ping(timeout time.Duration) error {
+	_ = this.rawConn.SetDeadline(time.Now().Add(timeout))
+	this.deadlineSet = true
+
+	request := &v1.Message{
+		MessageType: &v1.Message_GetRegionNamesRequest{
+			GetRegionNamesRequest: &v1.GetRegionNamesRequest{},
+		},
+	}
+
+	err := this.send(request)
+	if err != nil {
+		return err
+	}
+
+	_, err = readResponse(this)
+
+	return err
+}
+
 func (this *GeodeConnection) handshake() (err error) {
 	if this.handshakeDone {
 		return nil
 	}
 
 	request := &org_apache_geode_internal_protocol_protobuf.NewConnectionClientVersion{
-		MajorVersion: MAJOR_VERSION,
-		MinorVersion: MINOR_VERSION,
+		MajorVersion: clientVersion.Major,
+		MinorVersion: clientVersion.Minor,
 	}
 
-	err = writeMessage(this.rawConn, request)
+	err = this.send(request)
 	if err != nil {
 		return errors.New(fmt.Sprintf("unable to write handshake: %s", err.Error()))
 	}
 
-	data, err := readRawMessage(this.rawConn)
+	data, err := this.receive()
 	if err != nil {
 		return errors.New(fmt.Sprintf("unable to read handshake: %s", err.Error()))
 	}
 
-	p := proto.NewBuffer(data)
 	ack := &org_apache_geode_internal_protocol_protobuf.VersionAcknowledgement{}
-
-	if err := p.DecodeMessage(ack); err != nil {
+	if err := proto.Unmarshal(data, ack); err != nil {
 		return err
 	}
 
@@ -51,29 +211,29 @@ func (this *GeodeConnection) handshake() (err error) {
 		return errors.New("handshake did not succeed")
 	}
 
+	version, err := negotiateVersion(ack)
+	if err != nil {
+		return err
+	}
+
+	this.serverVersion = version
 	this.handshakeDone = true
 
 	return nil
 }
 
-func (this *GeodeConnection) authenticate(username, password string) error {
-	if this.authenticationDone {
-		return nil
-	}
-
-	creds := make(map[string]string)
-	creds["security-username"] = username
-	creds["security-password"] = password
-
+// authenticate sends the given credentials and value format to the server.
+func (this *GeodeConnection) authenticate(creds map[string]string, valueFormat string) error {
 	request := &v1.Message{
 		MessageType: &v1.Message_HandshakeRequest{
 			HandshakeRequest: &v1.HandshakeRequest{
 				Credentials: creds,
+				ValueFormat: valueFormat,
 			},
 		},
 	}
 
-	response, err := doOperationWithConnection(this.rawConn, request)
+	response, err := doOperationWithConnection(this, request)
 	if err != nil {
 		return err
 	}
@@ -86,3 +246,29 @@ func (this *GeodeConnection) authenticate(username, password string) error {
 
 	return nil
 }
+
+// disconnect tells the server why the connection is being closed and then closes it. Any error
+// from the server is ignored as the connection is going away regardless.
+func (this *GeodeConnection) disconnect(reason string) {
+	this.disconnectBy(reason, time.Now().Add(disconnectTimeout))
+}
+
+// disconnectBy is like disconnect but gives the server until the given deadline to acknowledge
+// the request.
+func (this *GeodeConnection) disconnectBy(reason string, deadline time.Time) {
+	if this.handshakeDone {
+		_ = this.rawConn.SetDeadline(deadline)
+
+		request := &v1.Message{
+			MessageType: &v1.Message_DisconnectClientRequest{
+				DisconnectClientRequest: &v1.DisconnectClientRequest{
+					Reason: reason,
+				},
+			},
+		}
+
+		_, _ = doOperationWithConnection(this, request)
+	}
+
+	_ = this.rawConn.Close()
+}
@@ -0,0 +1,96 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+)
+
+// An Operation describes a request passing through a connector's interceptors.
+type Operation struct {
+	// Name is the operation the request performs, such as "Get" or "PutAll".
+	Name string
+
+	// Region is the region the operation applies to, or an empty string for operations, such as
+	// queries, which are not addressed to a single region.
+	Region string
+
+	// Request is the message which will be sent to the server. Interceptors may modify or replace
+	// it before calling the next Invoker; changes only apply to this operation. It is reused once
+	// the operation completes, so it must not be retained afterwards.
+	Request *v1.Message
+
+	// Start is the time the operation began, before it was passed to any interceptor.
+	Start time.Time
+
+	// Server is the address of the server the request was last sent to. It is set once the
+	// operation has been performed.
+	Server string
+
+	// Duration is the time taken to perform the operation, including any retries but not the
+	// time spent in interceptors. It is set once the operation has been performed.
+	Duration time.Duration
+}
+
+// An Invoker continues an operation, returning the server's response.
+type Invoker func(ctx context.Context, op *Operation) (*v1.Message, error)
+
+// An Interceptor wraps every operation performed by a connector. It is given the operation and
+// the next Invoker in the chain, which it calls to continue the operation. It may inspect or
+// modify the request beforehand, and inspect the response, error, Server and Duration
+// afterwards. An interceptor may also return without calling next, for example to inject a fault.
+//
+// Interceptors see each operation once, however many times it is retried.
+type Interceptor func(ctx context.Context, op *Operation, next Invoker) (*v1.Message, error)
+
+// AddInterceptor adds an interceptor around the connector's operations. Interceptors are called
+// in the order they were added, so the first wraps all the others. Connectors created with
+// WithServerGroup or WithIdentity inherit the interceptors added before they were created.
+func (this *Protobuf) AddInterceptor(interceptor Interceptor) {
+	// Never append in place, as the slice may be shared with connectors derived from this one
+	this.interceptors = append(this.interceptors[:len(this.interceptors):len(this.interceptors)], interceptor)
+}
+
+// intercept passes an operation through the remaining interceptors before performing it.
+func (this *Protobuf) intercept(ctx context.Context, op *Operation, remaining []Interceptor) (*v1.Message, error) {
+	if len(remaining) == 0 {
+		start := time.Now()
+		response, server, err := this.retryOperation(ctx, op.Request)
+		op.Server = server
+		op.Duration = time.Since(start)
+		return response, err
+	}
+
+	return remaining[0](ctx, op, func(ctx context.Context, op *Operation) (*v1.Message, error) {
+		return this.intercept(ctx, op, remaining[1:])
+	})
+}
+
+// operationRegion returns the region a request applies to, if any.
+func operationRegion(request *v1.Message) string {
+	switch r := request.MessageType.(type) {
+	case *v1.Message_PutRequest:
+		return r.PutRequest.GetRegionName()
+	case *v1.Message_GetRequest:
+		return r.GetRequest.GetRegionName()
+	case *v1.Message_PutAllRequest:
+		return r.PutAllRequest.GetRegionName()
+	case *v1.Message_GetAllRequest:
+		return r.GetAllRequest.GetRegionName()
+	case *v1.Message_RemoveRequest:
+		return r.RemoveRequest.GetRegionName()
+	case *v1.Message_GetSizeRequest:
+		return r.GetSizeRequest.GetRegionName()
+	case *v1.Message_ExecuteFunctionOnRegionRequest:
+		return r.ExecuteFunctionOnRegionRequest.GetRegion()
+	case *v1.Message_KeySetRequest:
+		return r.KeySetRequest.GetRegionName()
+	case *v1.Message_ClearRequest:
+		return r.ClearRequest.GetRegionName()
+	case *v1.Message_PutIfAbsentRequest:
+		return r.PutIfAbsentRequest.GetRegionName()
+	}
+
+	return ""
+}
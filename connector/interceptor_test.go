@@ -0,0 +1,145 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Interceptors", func() {
+
+	var connection *connector.Protobuf
+	var fakeConn *connectorfakes.FakeConn
+
+	sizeResponse := &v1.Message{
+		MessageType: &v1.Message_GetSizeResponse{
+			GetSizeResponse: &v1.GetSizeResponse{Size: 7},
+		},
+	}
+
+	BeforeEach(func() {
+		connection, _, fakeConn = newFakeConnector()
+		respondWith(fakeConn, sizeResponse)
+	})
+
+	It("sees the operation, its request and its response", func() {
+		var seen connector.Operation
+		var response *v1.Message
+		connection.AddInterceptor(func(ctx context.Context, op *connector.Operation, next connector.Invoker) (*v1.Message, error) {
+			seen = *op
+			r, err := next(ctx, op)
+			response = r
+			return r, err
+		})
+
+		size, err := connection.Size("foo")
+		Expect(err).To(BeNil())
+		Expect(size).To(Equal(int32(7)))
+
+		Expect(seen.Name).To(Equal("GetSize"))
+		Expect(seen.Region).To(Equal("foo"))
+		Expect(seen.Request.GetGetSizeRequest().GetRegionName()).To(Equal("foo"))
+		Expect(proto.Equal(response, sizeResponse)).To(BeTrue())
+	})
+
+	It("records when the operation started and how long it took", func() {
+		fakeConn.ReadStub = func(b []byte) (int, error) {
+			time.Sleep(10 * time.Millisecond)
+			return writeFakeMessage(sizeResponse, b)
+		}
+
+		var start time.Time
+		var duration time.Duration
+		before := time.Now()
+		connection.AddInterceptor(func(ctx context.Context, op *connector.Operation, next connector.Invoker) (*v1.Message, error) {
+			start = op.Start
+			Expect(op.Duration).To(BeZero())
+			r, err := next(ctx, op)
+			duration = op.Duration
+			return r, err
+		})
+
+		_, err := connection.Size("foo")
+		Expect(err).To(BeNil())
+		Expect(start).To(BeTemporally(">=", before))
+		Expect(duration).To(BeNumerically(">=", 10*time.Millisecond))
+	})
+
+	It("calls interceptors in the order they were added", func() {
+		var calls []string
+		record := func(name string) connector.Interceptor {
+			return func(ctx context.Context, op *connector.Operation, next connector.Invoker) (*v1.Message, error) {
+				calls = append(calls, name+" before")
+				r, err := next(ctx, op)
+				calls = append(calls, name+" after")
+				return r, err
+			}
+		}
+		connection.AddInterceptor(record("first"))
+		connection.AddInterceptor(record("second"))
+
+		_, err := connection.Size("foo")
+		Expect(err).To(BeNil())
+		Expect(calls).To(Equal([]string{"first before", "second before", "second after", "first after"}))
+	})
+
+	It("allows an interceptor to fail an operation without sending it", func() {
+		injected := errors.New("injected fault")
+		connection.AddInterceptor(func(ctx context.Context, op *connector.Operation, next connector.Invoker) (*v1.Message, error) {
+			return nil, injected
+		})
+
+		_, err := connection.Size("foo")
+		Expect(err).To(Equal(injected))
+		Expect(fakeConn.WriteCallCount()).To(Equal(0))
+	})
+
+	It("only applies changes to a request to its own operation", func() {
+		getResponse := &v1.Message{
+			MessageType: &v1.Message_GetResponse{
+				GetResponse: &v1.GetResponse{},
+			},
+		}
+		respondWith(fakeConn, getResponse, getResponse)
+
+		intercepted := connection.WithServerGroup("")
+		intercepted.AddInterceptor(func(ctx context.Context, op *connector.Operation, next connector.Invoker) (*v1.Message, error) {
+			op.Request.MessageType.(*v1.Message_GetRequest).GetRequest = &v1.GetRequest{
+				RegionName: "bar",
+				Key:        op.Request.GetGetRequest().GetKey(),
+			}
+			return next(ctx, op)
+		})
+
+		_, err := intercepted.Get("foo", "A", nil)
+		Expect(err).To(BeNil())
+		_, err = connection.Get("foo", "B", nil)
+		Expect(err).To(BeNil())
+
+		Expect(writtenMessage(fakeConn, 0).GetGetRequest().GetRegionName()).To(Equal("bar"))
+
+		second := writtenMessage(fakeConn, 1).GetGetRequest()
+		Expect(second.GetRegionName()).To(Equal("foo"))
+		Expect(second.GetKey().GetStringResult()).To(Equal("B"))
+	})
+
+	It("does not add interceptors to connectors derived earlier", func() {
+		derived := connection.WithServerGroup("")
+		called := false
+		connection.AddInterceptor(func(ctx context.Context, op *connector.Operation, next connector.Invoker) (*v1.Message, error) {
+			called = true
+			return next(ctx, op)
+		})
+
+		_, err := derived.Size("foo")
+		Expect(err).To(BeNil())
+		Expect(called).To(BeFalse())
+	})
+})
@@ -0,0 +1,135 @@
+package connector
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// The weight given to each new latency sample in a server's moving average
+const latencyDecay = 0.2
+
+// ServerLoad describes a server which the pool may send an operation to.
+type ServerLoad struct {
+	// The address of the server, as host:port
+	Server string
+	// The number of connections to the server which are in use
+	InFlight int
+	// The number of idle connections to the server held by the pool
+	Idle int
+	// An exponentially weighted moving average of the time taken by operations on the server, or
+	// 0 if no operations have completed yet
+	Latency time.Duration
+}
+
+// A LoadBalancer chooses which server the pool uses for each operation. The pool reuses an idle
+// connection to the chosen server if there is one and otherwise creates a new connection, unless
+// the pool is already at its maximum size. New connections to servers added with AddServer, and to
+// discovered servers which the pool holds no connections to, are made to the chosen server. For
+// other servers discovered through locators, a locator is asked for a server instead, so that
+// servers which join the cluster are used; the new connection may therefore be to a different
+// server.
+//
+// Servers are those which were added with AddServer and every server returned by a locator. Servers
+// which have recently failed are left out unless the pool has idle connections to them. Choose is
+// called with the pool locked, so it must not block. The servers slice is reused by the pool and
+// must not be retained after Choose returns.
+type LoadBalancer interface {
+	// Choose returns the index of the server to use. servers is never empty.
+	Choose(servers []ServerLoad) int
+}
+
+// RoundRobin is a LoadBalancer which uses each server in turn. It is the default.
+type RoundRobin struct {
+	sync.Mutex
+	next int
+}
+
+var _ LoadBalancer = (*RoundRobin)(nil)
+
+func (this *RoundRobin) Choose(servers []ServerLoad) int {
+	this.Lock()
+	defer this.Unlock()
+
+	i := this.next % len(servers)
+	this.next = i + 1
+
+	return i
+}
+
+// Random is a LoadBalancer which chooses a server at random.
+type Random struct{}
+
+var _ LoadBalancer = (*Random)(nil)
+
+func (this *Random) Choose(servers []ServerLoad) int {
+	return rand.Intn(len(servers))
+}
+
+// LeastInFlight is a LoadBalancer which chooses the server with the fewest operations in
+// progress, preferring servers with idle connections when there is a tie.
+type LeastInFlight struct{}
+
+var _ LoadBalancer = (*LeastInFlight)(nil)
+
+func (this *LeastInFlight) Choose(servers []ServerLoad) int {
+	best := 0
+	for i, s := range servers {
+		b := servers[best]
+		if s.InFlight < b.InFlight || (s.InFlight == b.InFlight && s.Idle > b.Idle) {
+			best = i
+		}
+	}
+
+	return best
+}
+
+// LatencyWeighted is a LoadBalancer which chooses servers at random, in inverse proportion to
+// their average latency, so that faster servers receive more operations. Servers without a
+// latency measurement are treated as being as fast as the fastest server, so that they are
+// tried.
+type LatencyWeighted struct{}
+
+var _ LoadBalancer = (*LatencyWeighted)(nil)
+
+func (this *LatencyWeighted) Choose(servers []ServerLoad) int {
+	fastest := time.Duration(0)
+	for _, s := range servers {
+		if s.Latency > 0 && (fastest == 0 || s.Latency < fastest) {
+			fastest = s.Latency
+		}
+	}
+	if fastest == 0 {
+		return rand.Intn(len(servers))
+	}
+
+	weights := make([]float64, len(servers))
+	total := 0.0
+	for i, s := range servers {
+		latency := s.Latency
+		if latency <= 0 {
+			latency = fastest
+		}
+		weights[i] = 1 / float64(latency)
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		r -= w
+		if r < 0 {
+			return i
+		}
+	}
+
+	return len(servers) - 1
+}
+
+// movingAverage updates an exponentially weighted moving average with a new sample.
+func movingAverage(average, sample time.Duration) time.Duration {
+	if average <= 0 {
+		return sample
+	}
+
+	return time.Duration(latencyDecay*float64(sample) + (1-latencyDecay)*float64(average))
+}
@@ -0,0 +1,183 @@
+package connector_test
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Load balancing", func() {
+
+	var pool *connector.Pool
+	var first, second *countingListener
+
+	startCountingMember := func() *countingListener {
+		rawListener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		listener := &countingListener{Listener: rawListener}
+		serveFakeGeodeListener(listener, alwaysLocate(nil))
+
+		return listener
+	}
+
+	BeforeEach(func() {
+		first = startCountingMember()
+		second = startCountingMember()
+
+		pool = connector.NewPool()
+		pool.AddServer(hostAndPort(first))
+		pool.AddServer(hostAndPort(second))
+	})
+
+	AfterEach(func() {
+		first.Close()
+		second.Close()
+	})
+
+	It("spreads connections across servers in turn by default", func() {
+		var servers []string
+		for i := 0; i < 4; i++ {
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			servers = append(servers, gConn.GetRawConnection().RemoteAddr().String())
+			pool.ReturnConnection(gConn)
+		}
+
+		Expect(first.Accepted()).To(BeEquivalentTo(1))
+		Expect(second.Accepted()).To(BeEquivalentTo(1))
+		Expect(servers[0]).NotTo(Equal(servers[1]))
+		Expect(servers[2]).To(Equal(servers[0]))
+		Expect(servers[3]).To(Equal(servers[1]))
+	})
+
+	It("uses the configured load balancer", func() {
+		pool.SetLoadBalancer(&connector.LeastInFlight{})
+
+		busy, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+
+		for i := 0; i < 3; i++ {
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			Expect(gConn.GetRawConnection().RemoteAddr()).NotTo(Equal(busy.GetRawConnection().RemoteAddr()))
+			pool.ReturnConnection(gConn)
+		}
+	})
+
+	It("avoids servers which have recently failed", func() {
+		second.Close()
+
+		for i := 0; i < 4; i++ {
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			Expect(gConn.GetRawConnection().RemoteAddr().String()).To(Equal(first.Addr().String()))
+			pool.ReturnConnection(gConn)
+		}
+	})
+
+	It("asks the locator for each new connection to the servers it discovers", func() {
+		var located int32
+		locator := startFakeGeodeMember(func(*v1.GetServerRequest) *v1.Server {
+			if atomic.AddInt32(&located, 1)%2 == 1 {
+				return serverFromListener(first)
+			}
+			return serverFromListener(second)
+		})
+		defer locator.Close()
+
+		pool = connector.NewPool()
+		pool.AddLocator(hostAndPort(locator))
+
+		for i := 0; i < 6; i++ {
+			_, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+		}
+
+		Expect(atomic.LoadInt32(&located)).To(BeEquivalentTo(6))
+		Expect(first.Accepted()).To(BeEquivalentTo(3))
+		Expect(second.Accepted()).To(BeEquivalentTo(3))
+	})
+
+	It("uses discovered servers which the pool holds no connections to", func() {
+		var located int32
+		locator := startFakeGeodeMember(func(*v1.GetServerRequest) *v1.Server {
+			if atomic.AddInt32(&located, 1)%2 == 1 {
+				return serverFromListener(first)
+			}
+			return serverFromListener(second)
+		})
+		defer locator.Close()
+
+		pool = connector.NewPool()
+		pool.AddLocator(hostAndPort(locator))
+
+		toFirst, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+		toSecond, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+		Expect(toSecond.GetRawConnection().RemoteAddr().String()).To(Equal(second.Addr().String()))
+		pool.ReturnConnection(toFirst)
+		pool.DiscardConnection(toSecond)
+
+		var servers []string
+		for i := 0; i < 4; i++ {
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			servers = append(servers, gConn.GetRawConnection().RemoteAddr().String())
+			pool.ReturnConnection(gConn)
+		}
+
+		Expect(servers).To(ConsistOf(first.Addr().String(), second.Addr().String(), first.Addr().String(), second.Addr().String()))
+		Expect(atomic.LoadInt32(&located)).To(BeEquivalentTo(2))
+		Expect(second.Accepted()).To(BeEquivalentTo(2))
+	})
+
+	Context("Strategies", func() {
+		servers := []connector.ServerLoad{
+			{Server: "a:1", InFlight: 3, Idle: 0, Latency: 100 * time.Millisecond},
+			{Server: "b:1", InFlight: 1, Idle: 0, Latency: time.Millisecond},
+			{Server: "c:1", InFlight: 1, Idle: 2, Latency: 0},
+		}
+
+		It("uses each server in turn with RoundRobin", func() {
+			balancer := &connector.RoundRobin{}
+
+			var chosen []int
+			for i := 0; i < 4; i++ {
+				chosen = append(chosen, balancer.Choose(servers))
+			}
+
+			Expect(chosen).To(Equal([]int{0, 1, 2, 0}))
+		})
+
+		It("chooses the server with the fewest operations in progress with LeastInFlight", func() {
+			Expect((&connector.LeastInFlight{}).Choose(servers)).To(Equal(2))
+		})
+
+		It("chooses any server with Random", func() {
+			balancer := &connector.Random{}
+			for i := 0; i < 100; i++ {
+				Expect(balancer.Choose(servers)).To(BeNumerically("<", len(servers)))
+			}
+		})
+
+		It("favours faster servers with LatencyWeighted", func() {
+			balancer := &connector.LatencyWeighted{}
+
+			counts := make([]int, len(servers))
+			for i := 0; i < 1000; i++ {
+				counts[balancer.Choose(servers)] += 1
+			}
+
+			// The server without a measurement is treated as being as fast as the fastest
+			Expect(counts[0]).To(BeNumerically("<", 50))
+			Expect(counts[1]).To(BeNumerically(">", 350))
+			Expect(counts[2]).To(BeNumerically(">", 350))
+		})
+	})
+})
@@ -0,0 +1,145 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+)
+
+type locatorAddress struct {
+	host string
+	port int
+}
+
+// A locatorConnectionProvider discovers servers by asking a locator for a server and then
+// connecting to the server returned. Locators are tried in turn until one of them is able to
+// provide a server. The last locator to succeed is preferred for subsequent requests.
+type locatorConnectionProvider struct {
+	sync.Mutex
+	locators  []*locatorAddress
+	current   int
+	health    *serverHealth
+	transport *transport
+}
+
+// The number of servers requested from a single locator before moving on to the next locator.
+const maxLocatorServerAttempts = 3
+
+var _ ConnectionProvider = (*locatorConnectionProvider)(nil)
+
+func (this *locatorConnectionProvider) addLocator(host string, port int) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.locators = append(this.locators, &locatorAddress{
+		host: host,
+		port: port,
+	})
+}
+
+func (this *locatorConnectionProvider) GetGeodeConnection(ctx context.Context) (*GeodeConnection, error) {
+	return this.getGeodeConnectionForGroup(ctx, "")
+}
+
+// getGeodeConnectionForGroup connects to a server which is a member of the given server group.
+// An empty group means that any server may be used.
+func (this *locatorConnectionProvider) getGeodeConnectionForGroup(ctx context.Context, group string) (*GeodeConnection, error) {
+	this.Lock()
+	locators := make([]*locatorAddress, len(this.locators))
+	copy(locators, this.locators)
+	current := this.current
+	this.Unlock()
+
+	lastErr := errors.New("no locators available")
+
+	for i := range locators {
+		idx := (current + i) % len(locators)
+
+		// A locator may return a server which we are unable to connect to. In that case ask
+		// again, this time excluding the failed server.
+		for attempt := 0; attempt < maxLocatorServerAttempts; attempt++ {
+			server, err := locators[idx].getServer(ctx, this.transport, &v1.GetServerRequest{
+				ServerGroup:     group,
+				ExcludedServers: this.health.quarantined(),
+			})
+			if err != nil {
+				lastErr = err
+				break
+			}
+
+			host := server.GetHostname()
+			port := int(server.GetPort())
+
+			if this.health.isQuarantined(host, port) {
+				lastErr = errors.New(fmt.Sprintf("server %s is unavailable after a recent failure", serverKey(host, port)))
+				continue
+			}
+
+			c, err := this.transport.dialServer(ctx, host, port)
+			if err != nil {
+				this.health.failed(host, port)
+				lastErr = err
+				continue
+			}
+
+			this.Lock()
+			this.current = idx
+			this.Unlock()
+
+			return &GeodeConnection{
+				rawConn:            c,
+				host:               host,
+				port:               port,
+				address:            connectionAddress(c, host, port),
+				serverGroup:        group,
+				inUse:              false,
+				handshakeDone:      false,
+				authenticationDone: false,
+			}, nil
+		}
+	}
+
+	return nil, lastErr
+}
+
+// getServer opens a short-lived connection to the locator in order to request a server.
+func (this *locatorAddress) getServer(ctx context.Context, t *transport, request *v1.GetServerRequest) (*v1.Server, error) {
+	c, err := t.dialLocator(ctx, this.host, this.port)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	locatorConn := &GeodeConnection{
+		rawConn: c,
+	}
+
+	stopWatching := locatorConn.watchContext(ctx)
+	defer stopWatching()
+
+	err = locatorConn.handshake()
+	if err != nil {
+		return nil, err
+	}
+
+	message := &v1.Message{
+		MessageType: &v1.Message_GetServerRequest{
+			GetServerRequest: request,
+		},
+	}
+
+	response, err := doOperationWithConnection(locatorConn, message)
+	if err != nil {
+		return nil, err
+	}
+
+	server := response.GetGetServerResponse().GetServer()
+	if server == nil {
+		return nil, errors.New(fmt.Sprintf("locator %s did not return a server", serverKey(this.host, this.port)))
+	}
+
+	return server, nil
+}
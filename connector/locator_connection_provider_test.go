@@ -0,0 +1,268 @@
+package connector_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/protobuf"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locator", func() {
+
+	var pool *connector.Pool
+	var serverListener net.Listener
+
+	BeforeEach(func() {
+		pool = connector.NewPool()
+		serverListener = startFakeGeodeMember(alwaysLocate(nil))
+	})
+
+	AfterEach(func() {
+		serverListener.Close()
+	})
+
+	It("connects to the server returned by the locator", func() {
+		locatorListener := startFakeGeodeMember(alwaysLocate(serverFromListener(serverListener)))
+		defer locatorListener.Close()
+
+		host, port := hostAndPort(locatorListener)
+		pool.AddLocator(host, port)
+
+		gConn, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+		Expect(gConn.GetRawConnection().RemoteAddr().String()).To(Equal(serverListener.Addr().String()))
+	})
+
+	It("fails over to the next locator", func() {
+		deadListener := startFakeGeodeMember(alwaysLocate(nil))
+		deadHost, deadPort := hostAndPort(deadListener)
+		deadListener.Close()
+
+		locatorListener := startFakeGeodeMember(alwaysLocate(serverFromListener(serverListener)))
+		defer locatorListener.Close()
+
+		host, port := hostAndPort(locatorListener)
+		pool.AddLocator(deadHost, deadPort)
+		pool.AddLocator(host, port)
+
+		gConn, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+		Expect(gConn.GetRawConnection().RemoteAddr().String()).To(Equal(serverListener.Addr().String()))
+	})
+
+	It("returns an error when no locator can provide a server", func() {
+		locatorListener := startFakeGeodeMember(alwaysLocate(nil))
+		defer locatorListener.Close()
+
+		host, port := hostAndPort(locatorListener)
+		pool.AddLocator(host, port)
+
+		_, err := pool.GetConnection()
+		Expect(err).To(MatchError(ContainSubstring("did not return a server")))
+	})
+
+	Context("Server groups", func() {
+		var reportingListener net.Listener
+		var locatorListener net.Listener
+
+		BeforeEach(func() {
+			reportingListener = startFakeGeodeMember(alwaysLocate(nil))
+			locatorListener = startFakeGeodeMember(func(request *v1.GetServerRequest) *v1.Server {
+				switch request.GetServerGroup() {
+				case "reporting":
+					return serverFromListener(reportingListener)
+				case "":
+					return serverFromListener(serverListener)
+				}
+				return nil
+			})
+
+			host, port := hostAndPort(locatorListener)
+			pool.AddLocator(host, port)
+		})
+
+		AfterEach(func() {
+			reportingListener.Close()
+			locatorListener.Close()
+		})
+
+		It("requests servers in the pool's server group", func() {
+			pool.SetServerGroup("reporting")
+
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			Expect(gConn.GetRawConnection().RemoteAddr().String()).To(Equal(reportingListener.Addr().String()))
+		})
+
+		It("does not reuse a connection from a different server group", func() {
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			pool.ReturnConnection(gConn)
+
+			gConn, err = pool.GetConnectionForGroup("reporting")
+			Expect(err).To(BeNil())
+			Expect(gConn.GetRawConnection().RemoteAddr().String()).To(Equal(reportingListener.Addr().String()))
+		})
+
+		It("returns an error when no server is available in the group", func() {
+			_, err := pool.GetConnectionForGroup("unknown")
+			Expect(err).To(MatchError(ContainSubstring("did not return a server")))
+		})
+
+		It("returns an error when a group is requested without any locators", func() {
+			pool = connector.NewPool()
+			pool.AddServer("localhost", 40404)
+
+			_, err := pool.GetConnectionForGroup("reporting")
+			Expect(err).To(MatchError("unable to find a server in group reporting: no locators available"))
+		})
+	})
+})
+
+// startFakeGeodeMember starts a listener which acknowledges the connection handshake and then
+// answers every GetServerRequest with the server returned by locate. A nil server results in a
+// response without a server, as a locator would do if no servers were available.
+func startFakeGeodeMember(locate func(*v1.GetServerRequest) *v1.Server) net.Listener {
+	return startFakeGeodeMemberAt("127.0.0.1:0", locate)
+}
+
+func startFakeGeodeMemberAt(address string, locate func(*v1.GetServerRequest) *v1.Server) net.Listener {
+	listener, err := net.Listen("tcp", address)
+	Expect(err).To(BeNil())
+
+	return serveFakeGeodeListener(listener, locate)
+}
+
+func serveFakeGeodeListener(listener net.Listener, locate func(*v1.GetServerRequest) *v1.Server) net.Listener {
+	return serveFakeGeodeResponses(listener, locateResponder(locate))
+}
+
+// locateResponder answers GetServerRequests using locate, ignoring all other requests.
+func locateResponder(locate func(*v1.GetServerRequest) *v1.Server) func(*v1.Message) *v1.Message {
+	return func(request *v1.Message) *v1.Message {
+		if request.GetGetServerRequest() == nil {
+			return nil
+		}
+
+		return &v1.Message{
+			MessageType: &v1.Message_GetServerResponse{
+				GetServerResponse: &v1.GetServerResponse{
+					Server: locate(request.GetGetServerRequest()),
+				},
+			},
+		}
+	}
+}
+
+// startFakeGeodeServer starts a fake member which answers each request with the message returned
+// by respond, or not at all if it returns nil.
+func startFakeGeodeServer(respond func(*v1.Message) *v1.Message) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(BeNil())
+
+	return serveFakeGeodeResponses(listener, respond)
+}
+
+func serveFakeGeodeResponses(listener net.Listener, respond func(*v1.Message) *v1.Message) net.Listener {
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveFakeGeodeConnection(c, respond)
+		}
+	}()
+
+	return listener
+}
+
+func serveFakeGeodeMember(c net.Conn, locate func(*v1.GetServerRequest) *v1.Server) {
+	serveFakeGeodeConnection(c, locateResponder(locate))
+}
+
+func serveFakeGeodeConnection(c net.Conn, respond func(*v1.Message) *v1.Message) {
+	defer c.Close()
+	reader := bufio.NewReader(c)
+
+	version := &org_apache_geode_internal_protocol_protobuf.NewConnectionClientVersion{}
+	if err := readDelimitedMessage(reader, version); err != nil {
+		return
+	}
+
+	ack := &org_apache_geode_internal_protocol_protobuf.VersionAcknowledgement{
+		ServerMajorVersion: int32(version.MajorVersion),
+		ServerMinorVersion: int32(version.MinorVersion),
+		VersionAccepted:    true,
+	}
+	if err := writeDelimitedMessage(c, ack); err != nil {
+		return
+	}
+
+	for {
+		request := &v1.Message{}
+		if err := readDelimitedMessage(reader, request); err != nil {
+			return
+		}
+
+		response := respond(request)
+		if response == nil {
+			continue
+		}
+
+		if err := writeDelimitedMessage(c, response); err != nil {
+			return
+		}
+	}
+}
+
+func alwaysLocate(server *v1.Server) func(*v1.GetServerRequest) *v1.Server {
+	return func(*v1.GetServerRequest) *v1.Server {
+		return server
+	}
+}
+
+func readDelimitedMessage(reader *bufio.Reader, m proto.Message) error {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(data, m)
+}
+
+func writeDelimitedMessage(c net.Conn, m proto.Message) error {
+	p := proto.NewBuffer(nil)
+	if err := p.EncodeMessage(m); err != nil {
+		return err
+	}
+
+	_, err := c.Write(p.Bytes())
+	return err
+}
+
+func hostAndPort(listener net.Listener) (string, int) {
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func serverFromListener(listener net.Listener) *v1.Server {
+	host, port := hostAndPort(listener)
+	return &v1.Server{
+		Hostname: host,
+		Port:     int32(port),
+	}
+}
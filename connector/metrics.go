@@ -0,0 +1,302 @@
+package connector
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+)
+
+// Counters shared by every pool which has not been given its own Metrics
+var activeConnections = expvar.NewInt("activeConnections")
+var connectionsCreated = expvar.NewInt("connectionsCreated")
+var discardedConnections = expvar.NewInt("discardedConnections")
+
+// The bucket boundaries used for latency histograms
+var DefaultLatencyBuckets = []time.Duration{
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Metrics receives measurements of the operations performed with a pool and of its connections.
+// Methods are called while operations are in progress, so they must be safe for concurrent use
+// and must not block.
+type Metrics interface {
+	// OperationCompleted records an operation, such as "Get", and how long it took including any
+	// retries. If the operation failed, err is the error returned to the caller and code is the
+	// ErrorCode sent by the server, or ErrorCode_INVALID_ERROR_CODE if the server did not send
+	// one, for example because the connection failed.
+	OperationCompleted(operation string, duration time.Duration, err error, code v1.ErrorCode)
+
+	// BytesSent and BytesReceived record the size of each message, including its length prefix.
+	BytesSent(n int)
+	BytesReceived(n int)
+
+	// ConnectionWait records how long a caller waited to obtain a connection from the pool.
+	ConnectionWait(duration time.Duration)
+
+	ConnectionCreated()
+	ConnectionDiscarded()
+
+	// ConnectionAcquired and ConnectionReleased are called when a connection is handed out by the
+	// pool and when it is returned.
+	ConnectionAcquired()
+	ConnectionReleased()
+}
+
+// NopMetrics discards all measurements. It may be embedded by implementations of Metrics which
+// are only interested in some of them.
+type NopMetrics struct{}
+
+func (NopMetrics) OperationCompleted(operation string, duration time.Duration, err error, code v1.ErrorCode) {
+}
+func (NopMetrics) BytesSent(n int)                       {}
+func (NopMetrics) BytesReceived(n int)                   {}
+func (NopMetrics) ConnectionWait(duration time.Duration) {}
+func (NopMetrics) ConnectionCreated()                    {}
+func (NopMetrics) ConnectionDiscarded()                  {}
+func (NopMetrics) ConnectionAcquired()                   {}
+func (NopMetrics) ConnectionReleased()                   {}
+
+// globalMetrics maintains the process-wide connection counters used before a pool is given its
+// own Metrics.
+type globalMetrics struct {
+	NopMetrics
+}
+
+func (globalMetrics) ConnectionCreated()   { connectionsCreated.Add(1) }
+func (globalMetrics) ConnectionDiscarded() { discardedConnections.Add(1) }
+func (globalMetrics) ConnectionAcquired()  { activeConnections.Add(1) }
+func (globalMetrics) ConnectionReleased()  { activeConnections.Add(-1) }
+
+// SetMetrics sets where the pool's measurements are recorded. By default the pool only maintains
+// the activeConnections, connectionsCreated and discardedConnections expvar counters, which are
+// shared by every pool in the process. A nil Metrics discards all measurements.
+func (this *Pool) SetMetrics(metrics Metrics) {
+	this.Lock()
+	defer this.Unlock()
+
+	if metrics == nil {
+		metrics = NopMetrics{}
+	}
+
+	this.metrics = metrics
+}
+
+// recordOperation reports a completed operation to the pool's Metrics.
+func (this *Pool) recordOperation(request *v1.Message, duration time.Duration, err error) {
+	this.RLock()
+	metrics := this.metrics
+	this.RUnlock()
+
+	metrics.OperationCompleted(operationName(request), duration, err, errorCode(err))
+}
+
+// errorCode returns the ErrorCode sent by the server which caused an error, if any.
+func errorCode(err error) v1.ErrorCode {
+	if err == nil {
+		return v1.ErrorCode_INVALID_ERROR_CODE
+	}
+
+	var e *ServerError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return v1.ErrorCode_INVALID_ERROR_CODE
+}
+
+// ErrorCodeLabel returns the name used for an ErrorCode when counting errors. Errors which did not
+// come from the server are counted as CLIENT_ERROR.
+func ErrorCodeLabel(code v1.ErrorCode) string {
+	if code == v1.ErrorCode_INVALID_ERROR_CODE {
+		return "CLIENT_ERROR"
+	}
+
+	return code.String()
+}
+
+// ExpvarMetrics publishes a pool's measurements as expvar variables. They are grouped under a
+// single name, so that the pools in a process can be told apart. Errors are counted by operation
+// and then by error code, as they are by the Prometheus adapter.
+type ExpvarMetrics struct {
+	sync.Mutex
+	operations           *expvar.Map
+	errors               *expvar.Map
+	latencies            *expvar.Map
+	bytesSent            *expvar.Int
+	bytesReceived        *expvar.Int
+	connectionWait       *latencyHistogram
+	connectionsCreated   *expvar.Int
+	connectionsDiscarded *expvar.Int
+	activeConnections    *expvar.Int
+}
+
+// NewExpvarMetrics creates an ExpvarMetrics and publishes it under the given name. Like
+// expvar.Publish, it panics if the name is already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	this := &ExpvarMetrics{
+		operations:           new(expvar.Map),
+		errors:               new(expvar.Map),
+		latencies:            new(expvar.Map),
+		bytesSent:            new(expvar.Int),
+		bytesReceived:        new(expvar.Int),
+		connectionWait:       newLatencyHistogram(DefaultLatencyBuckets),
+		connectionsCreated:   new(expvar.Int),
+		connectionsDiscarded: new(expvar.Int),
+		activeConnections:    new(expvar.Int),
+	}
+
+	m := expvar.NewMap(name)
+	m.Set("operations", this.operations)
+	m.Set("errors", this.errors)
+	m.Set("latencySeconds", this.latencies)
+	m.Set("bytesSent", this.bytesSent)
+	m.Set("bytesReceived", this.bytesReceived)
+	m.Set("connectionWaitSeconds", this.connectionWait)
+	m.Set("connectionsCreated", this.connectionsCreated)
+	m.Set("connectionsDiscarded", this.connectionsDiscarded)
+	m.Set("activeConnections", this.activeConnections)
+
+	return this
+}
+
+func (this *ExpvarMetrics) OperationCompleted(operation string, duration time.Duration, err error, code v1.ErrorCode) {
+	this.operations.Add(operation, 1)
+	if err != nil {
+		this.errorCounts(operation).Add(ErrorCodeLabel(code), 1)
+	}
+
+	this.latency(operation).observe(duration)
+}
+
+// errorCounts returns the error counts for an operation, keyed by error code, creating them if
+// necessary.
+func (this *ExpvarMetrics) errorCounts(operation string) *expvar.Map {
+	if m, ok := this.errors.Get(operation).(*expvar.Map); ok {
+		return m
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	m, ok := this.errors.Get(operation).(*expvar.Map)
+	if !ok {
+		m = new(expvar.Map)
+		this.errors.Set(operation, m)
+	}
+
+	return m
+}
+
+// latency returns the latency histogram for an operation, creating it if necessary.
+func (this *ExpvarMetrics) latency(operation string) *latencyHistogram {
+	if h, ok := this.latencies.Get(operation).(*latencyHistogram); ok {
+		return h
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	h, ok := this.latencies.Get(operation).(*latencyHistogram)
+	if !ok {
+		h = newLatencyHistogram(DefaultLatencyBuckets)
+		this.latencies.Set(operation, h)
+	}
+
+	return h
+}
+
+func (this *ExpvarMetrics) BytesSent(n int) {
+	this.bytesSent.Add(int64(n))
+}
+
+func (this *ExpvarMetrics) BytesReceived(n int) {
+	this.bytesReceived.Add(int64(n))
+}
+
+func (this *ExpvarMetrics) ConnectionWait(duration time.Duration) {
+	this.connectionWait.observe(duration)
+}
+
+func (this *ExpvarMetrics) ConnectionCreated() {
+	this.connectionsCreated.Add(1)
+}
+
+func (this *ExpvarMetrics) ConnectionDiscarded() {
+	this.connectionsDiscarded.Add(1)
+}
+
+func (this *ExpvarMetrics) ConnectionAcquired() {
+	this.activeConnections.Add(1)
+}
+
+func (this *ExpvarMetrics) ConnectionReleased() {
+	this.activeConnections.Add(-1)
+}
+
+// A latencyHistogram counts durations in buckets. It is published as JSON holding the total count,
+// the sum in seconds and the cumulative count for the upper bound of each bucket, in seconds, in
+// the same way as a Prometheus histogram.
+type latencyHistogram struct {
+	bounds []time.Duration
+	counts []int64
+	count  int64
+	sum    int64
+}
+
+func newLatencyHistogram(bounds []time.Duration) *latencyHistogram {
+	return &latencyHistogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)),
+	}
+}
+
+func (this *latencyHistogram) observe(duration time.Duration) {
+	for i, bound := range this.bounds {
+		if duration <= bound {
+			atomic.AddInt64(&this.counts[i], 1)
+			break
+		}
+	}
+
+	atomic.AddInt64(&this.count, 1)
+	atomic.AddInt64(&this.sum, int64(duration))
+}
+
+func (this *latencyHistogram) String() string {
+	var b strings.Builder
+
+	sum := time.Duration(atomic.LoadInt64(&this.sum))
+	b.WriteString(`{"buckets": {`)
+
+	var cumulative int64
+	for i, bound := range this.bounds {
+		cumulative += atomic.LoadInt64(&this.counts[i])
+		fmt.Fprintf(&b, `"%s": %d, `, strconv.FormatFloat(bound.Seconds(), 'g', -1, 64), cumulative)
+	}
+
+	// Observations made while the buckets were being read are not in them, so count them last
+	count := atomic.LoadInt64(&this.count)
+	fmt.Fprintf(&b, `"+Inf": %d}, "count": %d, "sum": %s}`, count, count, strconv.FormatFloat(sum.Seconds(), 'g', -1, 64))
+
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package connector_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type recordedOperation struct {
+	operation string
+	err       error
+	code      v1.ErrorCode
+}
+
+// recordingMetrics keeps every measurement it is given
+type recordingMetrics struct {
+	sync.Mutex
+	operations    []recordedOperation
+	bytesSent     int
+	bytesReceived int
+	waits         int
+	created       int
+	discarded     int
+	active        int
+}
+
+func (this *recordingMetrics) OperationCompleted(operation string, duration time.Duration, err error, code v1.ErrorCode) {
+	this.Lock()
+	defer this.Unlock()
+	this.operations = append(this.operations, recordedOperation{operation, err, code})
+}
+
+func (this *recordingMetrics) BytesSent(n int)                       { this.Lock(); this.bytesSent += n; this.Unlock() }
+func (this *recordingMetrics) BytesReceived(n int)                   { this.Lock(); this.bytesReceived += n; this.Unlock() }
+func (this *recordingMetrics) ConnectionWait(duration time.Duration) { this.Lock(); this.waits += 1; this.Unlock() }
+func (this *recordingMetrics) ConnectionCreated()                    { this.Lock(); this.created += 1; this.Unlock() }
+func (this *recordingMetrics) ConnectionDiscarded()                  { this.Lock(); this.discarded += 1; this.Unlock() }
+func (this *recordingMetrics) ConnectionAcquired()                   { this.Lock(); this.active += 1; this.Unlock() }
+func (this *recordingMetrics) ConnectionReleased()                   { this.Lock(); this.active -= 1; this.Unlock() }
+
+var expvarRuns int32
+
+var _ = Describe("Metrics", func() {
+
+	var connection *connector.Protobuf
+	var fakeConn *connectorfakes.FakeConn
+	var pool *connector.Pool
+	var metrics *recordingMetrics
+
+	putResponse := &v1.Message{
+		MessageType: &v1.Message_PutResponse{
+			PutResponse: &v1.PutResponse{},
+		},
+	}
+
+	BeforeEach(func() {
+		metrics = &recordingMetrics{}
+		connection, pool, fakeConn = newFakeConnector()
+		pool.SetMetrics(metrics)
+	})
+
+	It("records successful operations", func() {
+		fakeConn.WriteStub = func(b []byte) (int, error) {
+			return len(b), nil
+		}
+		respondWith(fakeConn, putResponse)
+
+		Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+		Expect(metrics.operations).To(Equal([]recordedOperation{{operation: "Put"}}))
+		Expect(metrics.waits).To(Equal(1))
+		Expect(metrics.active).To(Equal(0))
+		Expect(metrics.bytesSent).To(Equal(len(fakeConn.WriteArgsForCall(0))))
+		Expect(metrics.bytesReceived).To(Equal(proto.Size(putResponse) + 1))
+	})
+
+	It("records the error code of failed operations", func() {
+		respondWith(fakeConn, &v1.Message{
+			MessageType: &v1.Message_ErrorResponse{
+				ErrorResponse: &v1.ErrorResponse{
+					Error: &v1.Error{
+						ErrorCode: v1.ErrorCode_AUTHORIZATION_FAILED,
+						Message:   "not authorized",
+					},
+				},
+			},
+		})
+
+		err := connection.Put("foo", "a", 1)
+		Expect(err).ToNot(BeNil())
+
+		Expect(metrics.operations).To(HaveLen(1))
+		Expect(metrics.operations[0].err).To(Equal(err))
+		Expect(metrics.operations[0].code).To(Equal(v1.ErrorCode_AUTHORIZATION_FAILED))
+		Expect(metrics.discarded).To(Equal(1))
+	})
+
+	It("counts a connection discarded after the pool was closed once", func() {
+		gConn, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+
+		Expect(pool.Close()).To(Succeed())
+		Expect(metrics.discarded).To(Equal(1))
+
+		pool.DiscardConnection(gConn)
+		Expect(metrics.discarded).To(Equal(1))
+	})
+
+	It("publishes measurements with expvar", func() {
+		// expvar names cannot be reused, so the suite may only be run repeatedly with a new name
+		name := fmt.Sprintf("geodeMetricsTest%d", atomic.AddInt32(&expvarRuns, 1))
+		expvarMetrics := connector.NewExpvarMetrics(name)
+		expvarMetrics.OperationCompleted("Get", 2*time.Millisecond, nil, v1.ErrorCode_INVALID_ERROR_CODE)
+		expvarMetrics.OperationCompleted("Get", 20*time.Second, connector.ErrPoolClosed, v1.ErrorCode_INVALID_ERROR_CODE)
+		expvarMetrics.OperationCompleted("Put", time.Millisecond, connector.ErrAuthorizationFailed, v1.ErrorCode_AUTHORIZATION_FAILED)
+		expvarMetrics.BytesSent(10)
+
+		var published struct {
+			Operations     map[string]int
+			Errors         map[string]map[string]int
+			LatencySeconds map[string]struct {
+				Count   int
+				Sum     float64
+				Buckets map[string]int
+			}
+			BytesSent int
+		}
+		Expect(json.Unmarshal([]byte(expvar.Get(name).String()), &published)).To(Succeed())
+
+		Expect(published.Operations).To(Equal(map[string]int{"Get": 2, "Put": 1}))
+		Expect(published.Errors).To(Equal(map[string]map[string]int{
+			"Get": {"CLIENT_ERROR": 1},
+			"Put": {"AUTHORIZATION_FAILED": 1},
+		}))
+		Expect(published.BytesSent).To(Equal(10))
+
+		latency := published.LatencySeconds["Get"]
+		Expect(latency.Count).To(Equal(2))
+		Expect(latency.Sum).To(BeNumerically("~", 20.002))
+		Expect(latency.Buckets["0.001"]).To(Equal(0))
+		Expect(latency.Buckets["0.0025"]).To(Equal(1))
+		Expect(latency.Buckets["10"]).To(Equal(1))
+		Expect(latency.Buckets["+Inf"]).To(Equal(2))
+	})
+})
@@ -1,147 +1,704 @@
 package connector
 
 import (
+	"context"
+	"crypto/tls"
 	"net"
 	"sync"
 	"errors"
-	"expvar"
+	"fmt"
+	"time"
 )
 
-var activeConnections = expvar.NewInt("activeConnections")
-var connectionsCreated = expvar.NewInt("connectionsCreated")
-var discardedConnections = expvar.NewInt("discardedConnections")
-
 type AuthenticationError string
 
 func (e AuthenticationError) Error() string {
 	return string(e)
 }
 
+// A ConnectionProvider creates new connections to a server. The returned connection has not yet
+// performed the protocol handshake.
 type ConnectionProvider interface {
-	GetGeodeConnection() *GeodeConnection
+	GetGeodeConnection(ctx context.Context) (*GeodeConnection, error)
 }
 
 type Pool struct {
 	sync.RWMutex
-	recentConnections     []*GeodeConnection
-	providers             []ConnectionProvider
-	authenticationEnabled bool
-	username              string
-	password              string
+	recentConnections    []*GeodeConnection
+	providers            []ConnectionProvider
+	locator              *locatorConnectionProvider
+	discovered           []*serverConnectionProvider
+	serverGroup          string
+	health               *serverHealth
+	transport            *transport
+	balancer             LoadBalancer
+	latencies            map[string]time.Duration
+	loadScratch          []ServerLoad
+	candidateScratch     []serverCandidate
+	credentials          CredentialsProvider
+	serializer           ValueSerializer
+	metrics              Metrics
+	tracer               Tracer
+	maxConnections       int
+	maxMessageSize       int
+	minIdleConnections   int
+	maxIdleConnections   int
+	acquireTimeout       time.Duration
+	available            chan struct{}
+	filling              bool
+	idleTimeout          time.Duration
+	maxLifetime          time.Duration
+	disconnectOnEviction bool
+	validateOnBorrow     bool
+	livenessInterval     time.Duration
+	livenessTimeout      time.Duration
+	pending              int
+	reaping              bool
+	closed               bool
+	shutdown             chan struct{}
 }
 
 func NewPool() *Pool {
 	return &Pool{
-		authenticationEnabled: false,
+		health:    newServerHealth(),
+		transport: newTransport(),
+		balancer:  &RoundRobin{},
+		latencies: make(map[string]time.Duration),
+		metrics:   globalMetrics{},
+		shutdown:  make(chan struct{}),
 	}
 }
 
 func (this *Pool) AddConnection(c net.Conn, handshakeDone bool) {
+	now := time.Now()
 	gConn := &GeodeConnection{
 		rawConn:            c,
+		address:            connectionAddress(c, "", 0),
 		handshakeDone:      handshakeDone,
 		authenticationDone: false,
 		inUse:              false,
+		createdAt:          now,
+		lastUsed:           now,
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	gConn.maxMessageSize = this.maxMessageSize
+	gConn.metrics = this.metrics
+	if handshakeDone {
+		// The version negotiated by the caller is not known, so assume the client's
+		gConn.serverVersion = clientVersion
 	}
 
 	this.recentConnections = append(this.recentConnections, gConn)
 }
 
+// AddLocator adds a locator which will be used to discover servers. Multiple locators may
+// be added, in which case they are tried in turn until one is able to provide a server.
 func (this *Pool) AddLocator(host string, port int) {
-	// TODO: Implement me
+	if this.locator == nil {
+		this.locator = &locatorConnectionProvider{
+			health:    this.health,
+			transport: this.transport,
+		}
+		this.providers = append(this.providers, this.locator)
+	}
+
+	this.locator.addLocator(host, port)
 }
 
 func (this *Pool) AddServer(host string, port int) {
 	this.providers = append(this.providers, &serverConnectionProvider{
-		host,
-		port,
+		host:      host,
+		port:      port,
+		health:    this.health,
+		transport: this.transport,
 	})
 }
 
+// SetDialer replaces the Dialer used to connect to servers and locators. By default a net.Dialer
+// with a connection timeout and TCP keep-alives is used.
+func (this *Pool) SetDialer(dialer Dialer) {
+	this.transport.setDialer(dialer)
+}
+
+// SetTLSConfig enables TLS for connections to servers using the given configuration. Client
+// certificates for mutual TLS and custom certificate authorities may be provided in the
+// configuration. If the configuration does not specify a ServerName, the host name of each
+// server is used for SNI and certificate verification.
+func (this *Pool) SetTLSConfig(config *tls.Config) {
+	this.transport.setServerTLS(config)
+}
+
+// SetLocatorTLSConfig enables TLS for connections to locators using the given configuration. It
+// behaves in the same way as SetTLSConfig.
+func (this *Pool) SetLocatorTLSConfig(config *tls.Config) {
+	this.transport.setLocatorTLS(config)
+}
+
+// SetFailureBackoff configures how long a server is avoided after a connection to it fails. The
+// first failure quarantines the server for the initial duration, with each consecutive failure
+// doubling that time up to the given maximum. Once the quarantine expires the server is tried
+// again.
+func (this *Pool) SetFailureBackoff(initial, max time.Duration) {
+	this.health.setBackoff(initial, max)
+}
+
+// SetServerGroup restricts the connections handed out by GetConnection to servers in the given
+// server group. Server groups are resolved by locators, so at least one locator must be added
+// with AddLocator.
+func (this *Pool) SetServerGroup(group string) {
+	this.serverGroup = group
+}
+
+// SetMaxConnections limits the total number of connections, both idle and in use, which the pool
+// will hold. A value of 0, the default, means that the pool is unbounded.
+func (this *Pool) SetMaxConnections(max int) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.maxConnections = max
+}
+
+// SetMinIdleConnections sets the number of idle connections which the pool tries to keep ready
+// for use. Idle connections are created in the background once the pool is in use.
+func (this *Pool) SetMinIdleConnections(min int) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.minIdleConnections = min
+}
+
+// SetMaxIdleConnections limits the number of idle connections kept by the pool. Connections
+// returned to a pool which already has this many idle connections are closed. A value of 0,
+// the default, means that idle connections are never closed.
+func (this *Pool) SetMaxIdleConnections(max int) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.maxIdleConnections = max
+}
+
+// SetMaxMessageSize limits the size of the messages accepted from servers. A larger message
+// results in a FrameTooLargeError and the connection is closed. It applies to connections created
+// after it is called. A value of 0 uses the default of 64MB.
+func (this *Pool) SetMaxMessageSize(size int) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.maxMessageSize = size
+}
+
+// SetAcquireTimeout sets how long GetConnection waits for a connection to become available when
+// the pool has reached its maximum size. A value of 0, the default, means wait indefinitely.
+func (this *Pool) SetAcquireTimeout(timeout time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.acquireTimeout = timeout
+}
+
 func (this *Pool) GetConnection() (*GeodeConnection, error) {
-	var gConn *GeodeConnection
-	var err error
+	return this.GetConnectionForGroup(this.serverGroup)
+}
+
+// GetConnectionForGroup returns a connection to a server in the given server group. Servers added
+// with AddServer are not associated with any group and are only used when the group is empty.
+func (this *Pool) GetConnectionForGroup(group string) (*GeodeConnection, error) {
+	return this.GetConnectionContext(context.Background(), group)
+}
+
+// GetConnectionContext returns a connection to a server in the given server group, or to any
+// server if the group is empty.
+//
+// If the pool has reached its maximum number of connections, GetConnectionContext waits for a
+// connection to be returned until either the context is done or the timeout configured with
+// SetAcquireTimeout expires.
+func (this *Pool) GetConnectionContext(ctx context.Context, group string) (*GeodeConnection, error) {
+	return this.GetConnectionAs(ctx, group, nil)
+}
+
+// GetConnectionAs is like GetConnectionContext but returns a connection authenticated as the given
+// identity. A nil identity uses the pool's own credentials. An identity without a name is rejected
+// with ErrUnnamedIdentity, and one without credentials with ErrIdentityWithoutCredentials.
+func (this *Pool) GetConnectionAs(ctx context.Context, group string, identity *Identity) (*GeodeConnection, error) {
+	return this.getConnection(ctx, group, identity, false)
+}
+
+// getConnection returns a connection for the given group and identity. If fresh is true, a new
+// connection is always created rather than using an idle one, for example because the credentials
+// which idle connections were authenticated with may have been rejected.
+func (this *Pool) getConnection(ctx context.Context, group string, identity *Identity, fresh bool) (*GeodeConnection, error) {
+	if identity != nil && identity.Name == "" {
+		return nil, ErrUnnamedIdentity
+	}
+
+	if identity != nil && identity.Credentials == nil {
+		return nil, ErrIdentityWithoutCredentials
+	}
+
+	ctx, span := this.startSpan(ctx, "AcquireConnection")
+	gConn, err := this.acquireConnection(ctx, group, identity, fresh)
+	if span != nil && gConn != nil {
+		span.SetAttribute(AttributeServer, gConn.serverAddress())
+	}
+	finishSpan(span, err)
+
+	return gConn, err
+}
+
+func (this *Pool) acquireConnection(ctx context.Context, group string, identity *Identity, fresh bool) (*GeodeConnection, error) {
+	var timeout <-chan time.Time
+	start := time.Now()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	this.Lock()
+
+	for {
+		if this.closed {
+			this.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		gConn, created, err := this.chooseConnection(ctx, group, identity, fresh)
+		if err != nil {
+			this.Unlock()
+			return nil, err
+		}
+
+		if gConn != nil {
+			// Reserve the connection so that the lock can be released while it is checked and prepared
+			gConn.inUse = true
+			validate := !created && this.validateOnBorrow && gConn.handshakeDone
+			this.Unlock()
+
+			if validate && gConn.isClosed() {
+				this.Lock()
+				this.discardConnection(gConn)
+				this.notifyAvailable()
+				continue
+			}
+
+			return this.useConnection(ctx, gConn, identity, start)
+		}
+
+		// Idle connections to servers in other groups, or for other identities, only take up space
+		// which we need. So do idle connections when a new one is wanted.
+		if victim := this.anyIdleConnection(); victim != nil {
+			this.discardConnection(victim)
+			continue
+		}
+
+		if timeout == nil && this.acquireTimeout > 0 {
+			timer := time.NewTimer(this.acquireTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		available := this.waitForAvailable()
+		this.Unlock()
+		select {
+		case <-available:
+		case <-timeout:
+			return nil, errors.New("timed out waiting for a connection")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		this.Lock()
+	}
+}
+
+// useConnection prepares a connection which has been reserved for the caller and hands it out. The
+// time since the caller started waiting for the connection is recorded in the pool's metrics.
+// MUST NOT hold the pool lock when calling
+func (this *Pool) useConnection(ctx context.Context, gConn *GeodeConnection, identity *Identity, start time.Time) (*GeodeConnection, error) {
+	err := this.prepareConnection(ctx, gConn, identity)
 
 	this.Lock()
 	defer this.Unlock()
 
-	// First let's check the recent connections
+	if err != nil {
+		this.discardConnection(gConn)
+		this.notifyAvailable()
+		return nil, err
+	}
+
+	if this.closed {
+		this.retireConnection(gConn)
+		return nil, ErrPoolClosed
+	}
+
+	gConn.metrics = this.metrics
+	gConn.acquired = true
+	this.metrics.ConnectionAcquired()
+	this.metrics.ConnectionWait(time.Since(start))
+
+	this.fillIdleConnections()
+
+	return gConn, nil
+}
+
+// MUST hold the pool lock when calling
+func (this *Pool) idleConnection(group string, identity *Identity) *GeodeConnection {
+	var gConn *GeodeConnection
+
 	for _, c := range this.recentConnections {
-		if ! c.inUse {
+		if ! c.inUse && c.usableFor(group, identity) {
 			gConn = c
 		}
 	}
 
-	if gConn == nil {
-		for i := len(this.providers) - 1; i >= 0; i-- {
-			gConn = this.providers[i].GetGeodeConnection()
-			if gConn != nil {
+	return gConn
+}
+
+// anyIdleConnection returns the most recently added idle connection, whatever its group and
+// identity.
+// MUST hold the pool lock when calling
+func (this *Pool) anyIdleConnection() *GeodeConnection {
+	var gConn *GeodeConnection
+
+	for _, c := range this.recentConnections {
+		if !c.inUse {
+			gConn = c
+		}
+	}
+
+	return gConn
+}
+
+// idleConnectionTo returns the most recently added idle connection to the given server.
+// MUST hold the pool lock when calling
+func (this *Pool) idleConnectionTo(group string, identity *Identity, server string) *GeodeConnection {
+	var gConn *GeodeConnection
+
+	for _, c := range this.recentConnections {
+		if !c.inUse && c.usableFor(group, identity) && c.serverAddress() == server {
+			gConn = c
+		}
+	}
+
+	return gConn
+}
+
+// hasCapacity returns true if another connection may be created. Connections which are still
+// being created count towards the maximum.
+// MUST hold the pool lock when calling
+func (this *Pool) hasCapacity() bool {
+	return this.maxConnections <= 0 || len(this.recentConnections)+this.pending < this.maxConnections
+}
+
+// MUST hold the pool lock when calling
+func (this *Pool) idleCount() int {
+	count := 0
+	for _, c := range this.recentConnections {
+		if !c.inUse {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+// createConnection obtains a new connection and adds it to the pool, reserved for the caller. The
+// preferred provider, if any, is tried first and the others are tried in turn if it fails. The
+// connection is only used for the given identity.
+//
+// The pool lock is released while connecting, so that other connections can be used and created
+// in the meantime. Until it is added to the pool, the new connection is counted as pending so
+// that the maximum number of connections is respected.
+// MUST hold the pool lock when calling
+func (this *Pool) createConnection(ctx context.Context, group string, identity *Identity, preferred ConnectionProvider) (*GeodeConnection, error) {
+	var providers []ConnectionProvider
+
+	if group == "" {
+		providers = append(providers, this.providers...)
+		if preferred != nil {
+			providers = append([]ConnectionProvider{preferred}, providers...)
+		}
+
+		if len(providers) == 0 {
+			return nil, errors.New("no connections available")
+		}
+	} else if preferred == nil && this.locator == nil {
+		return nil, errors.New(fmt.Sprintf("unable to find a server in group %s: no locators available", group))
+	}
+
+	locator := this.locator
+
+	this.pending += 1
+	this.Unlock()
+
+	var gConn *GeodeConnection
+	var err error
+
+	if group == "" {
+		for i, provider := range providers {
+			if i > 0 && provider == preferred {
+				continue
+			}
+
+			gConn, err = provider.GetGeodeConnection(ctx)
+			if err == nil {
 				break
 			}
-			this.providers = append(this.providers[:i], this.providers[i+1:]...)
+		}
+	} else {
+		if preferred != nil {
+			gConn, err = preferred.GetGeodeConnection(ctx)
 		}
 
-		if gConn != nil {
-			this.recentConnections = append(this.recentConnections, gConn)
-			connectionsCreated.Add(1)
+		if (preferred == nil || err != nil) && locator != nil {
+			gConn, err = locator.getGeodeConnectionForGroup(ctx, group)
 		}
-	}
 
-	if gConn == nil {
-		return nil, errors.New("no connections available")
+		if err == nil {
+			gConn.serverGroup = group
+		}
 	}
 
-	err = gConn.handshake()
+	this.Lock()
+	this.pending -= 1
+	this.notifyAvailable()
+
 	if err != nil {
-		this.discardConnection(gConn)
-		return nil, err
+		return nil, fmt.Errorf("no connections available: %w", err)
 	}
 
-	if this.authenticationEnabled {
-		err = gConn.authenticate(this.username, this.password)
-		if err != nil {
-			this.discardConnection(gConn)
-			return nil, err
-		}
+	if gConn.address == "" {
+		gConn.address = connectionAddress(gConn.rawConn, gConn.host, gConn.port)
 	}
 
+	if this.closed {
+		_ = gConn.rawConn.Close()
+		return nil, ErrPoolClosed
+	}
+
+	this.discoverServer(gConn.host, gConn.port)
+
+	gConn.createdAt = time.Now()
+	gConn.lastUsed = gConn.createdAt
 	gConn.inUse = true
-	activeConnections.Add(1)
+	gConn.maxMessageSize = this.maxMessageSize
+	gConn.identity = identity.name()
+	gConn.metrics = this.metrics
+
+	this.recentConnections = append(this.recentConnections, gConn)
+	this.metrics.ConnectionCreated()
 
 	return gConn, nil
 }
 
+// prepareConnection performs the handshake and authentication for a connection, if these have
+// not already been done. The connection must be reserved by the caller.
+// MUST NOT hold the pool lock when calling
+func (this *Pool) prepareConnection(ctx context.Context, gConn *GeodeConnection, identity *Identity) error {
+	provider := this.credentialsProvider(identity)
+
+	// A HandshakeRequest is only needed to send credentials or to select a value format
+	authenticate := provider != nil || this.valueFormat() != ""
+
+	if gConn.handshakeDone && (gConn.authenticationDone || !authenticate) {
+		return nil
+	}
+
+	stopWatching := gConn.watchContext(ctx)
+	defer stopWatching()
+
+	if !gConn.handshakeDone {
+		_, span := this.startSpan(ctx, "Handshake")
+		if span != nil {
+			span.SetAttribute(AttributeServer, gConn.serverAddress())
+		}
+		err := gConn.handshake()
+		finishSpan(span, err)
+		if err != nil {
+			this.health.failed(gConn.host, gConn.port)
+			return err
+		}
+
+		this.health.succeeded(gConn.host, gConn.port)
+	}
+
+	if authenticate && !gConn.authenticationDone {
+		err := this.authenticateConnection(ctx, gConn, provider)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fillIdleConnections starts creating connections in the background if the pool has fewer idle
+// connections than the configured minimum.
+// MUST hold the pool lock when calling
+func (this *Pool) fillIdleConnections() {
+	if this.filling || !this.needsIdleConnection() {
+		return
+	}
+
+	this.filling = true
+	go func() {
+		this.Lock()
+		defer this.Unlock()
+
+		for this.needsIdleConnection() {
+			gConn, err := this.createConnection(context.Background(), this.serverGroup, nil, this.chooseProvider(this.serverGroup))
+			if err != nil {
+				break
+			}
+
+			this.Unlock()
+			err = this.prepareConnection(context.Background(), gConn, nil)
+			this.Lock()
+
+			if err != nil {
+				this.discardConnection(gConn)
+				this.notifyAvailable()
+				break
+			}
+
+			if this.closed {
+				this.retireConnection(gConn)
+				break
+			}
+
+			gConn.inUse = false
+			this.notifyAvailable()
+		}
+
+		this.filling = false
+	}()
+}
+
+// MUST hold the pool lock when calling
+func (this *Pool) needsIdleConnection() bool {
+	if this.closed || this.minIdleConnections <= 0 {
+		return false
+	}
+
+	if !this.hasCapacity() {
+		return false
+	}
+
+	return this.idleCount() < this.minIdleConnections
+}
+
+// notifyAvailable wakes up any callers waiting for a connection.
+// MUST hold the pool lock when calling
+func (this *Pool) notifyAvailable() {
+	if this.available != nil {
+		close(this.available)
+		this.available = nil
+	}
+}
+
+// waitForAvailable returns a channel which is closed the next time notifyAvailable is called. The
+// channel is only created when someone waits, so that notifying nobody does not allocate.
+// MUST hold the pool lock when calling
+func (this *Pool) waitForAvailable() <-chan struct{} {
+	if this.available == nil {
+		this.available = make(chan struct{})
+	}
+
+	return this.available
+}
+
+// ReturnConnection makes a connection available for reuse. If the pool already has the maximum
+// number of idle connections the connection is closed instead, and if it has exceeded its maximum
+// lifetime it is evicted in the same way as an idle connection would be. Connections returned to
+// a pool which is shutting down are disconnected.
 func (this *Pool) ReturnConnection(gConn *GeodeConnection) {
 	this.Lock()
-	defer this.Unlock()
+
+	// A connection which failed is discarded before it is returned, and connections in use may be
+	// removed when the pool is shut down. Either way, nothing remains to be done.
+	if !this.hasConnection(gConn) {
+		this.Unlock()
+		return
+	}
 
 	gConn.inUse = false
-	activeConnections.Add(-1)
+	gConn.lastUsed = time.Now()
+	this.releaseConnection(gConn)
+
+	expired := false
+	if this.closed {
+		this.retireConnection(gConn)
+	} else if this.maxIdleConnections > 0 && this.idleCount() > this.maxIdleConnections {
+		this.discardConnection(gConn)
+	} else if this.maxLifetime > 0 && gConn.lastUsed.Sub(gConn.createdAt) > this.maxLifetime {
+		// Expired connections are evicted just as the reaper would, once the lock is released
+		this.removeConnection(gConn)
+		this.metrics.ConnectionDiscarded()
+		expired = true
+	}
+
+	disconnect := this.disconnectOnEviction
+	this.notifyAvailable()
+	this.Unlock()
+
+	if expired {
+		closeEvicted(gConn, lifetimeExceededReason, disconnect)
+	}
 }
 
+// releaseConnection records that a connection handed out by the pool is no longer in use.
+// MUST hold the pool lock when calling
+func (this *Pool) releaseConnection(gConn *GeodeConnection) {
+	if gConn.acquired {
+		gConn.acquired = false
+		this.metrics.ConnectionReleased()
+	}
+}
+
+// MUST hold the pool lock when calling
+func (this *Pool) hasConnection(gConn *GeodeConnection) bool {
+	for _, c := range this.recentConnections {
+		if gConn == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// discardConnection removes a connection from the pool and closes it. It is only counted as
+// discarded if it was still in the pool, as a connection may be discarded after shutdown has
+// already removed it.
 // MUST hold the pool lock when calling
 func (this *Pool) discardConnection(gConn *GeodeConnection) {
+	if this.removeConnection(gConn) {
+		this.metrics.ConnectionDiscarded()
+	}
+	_ = gConn.rawConn.Close()
+}
+
+// removeConnection removes a connection from the pool, returning false if it was not present.
+// MUST hold the pool lock when calling
+func (this *Pool) removeConnection(gConn *GeodeConnection) bool {
 	for i, c := range this.recentConnections {
 		if gConn == c {
 			this.recentConnections = append(this.recentConnections[:i], this.recentConnections[i+1:]...)
-			break
+			return true
 		}
 	}
 
-	_ = gConn.rawConn.Close()
+	return false
 }
 
 // DiscardConnection is used publicly as it holds the necessary lock
 func (this *Pool) DiscardConnection(gConn *GeodeConnection) {
 	this.Lock()
-	this.discardConnection(gConn)
-	this.Unlock()
+	defer this.Unlock()
 
-	discardedConnections.Add(1)
+	this.releaseConnection(gConn)
+	this.discardConnection(gConn)
+	this.notifyAvailable()
+	this.fillIdleConnections()
 }
 
-func (this *Pool) AddCredentials(username, password string) {
-	this.username = username
-	this.password = password
-	this.authenticationEnabled = true
-}
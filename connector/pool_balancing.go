@@ -0,0 +1,221 @@
+package connector
+
+import (
+	"context"
+	"time"
+)
+
+// A server which the pool may use, and how to create a new connection to it.
+type serverCandidate struct {
+	host     string
+	port     int
+	provider ConnectionProvider
+}
+
+// SetLoadBalancer sets the strategy used to spread operations across servers. By default servers
+// are used in turn, with RoundRobin.
+func (this *Pool) SetLoadBalancer(balancer LoadBalancer) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.balancer = balancer
+}
+
+// recordLatency adds the time taken by an operation to the moving average for its server.
+func (this *Pool) recordLatency(gConn *GeodeConnection, latency time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+
+	server := gConn.serverAddress()
+	this.latencies[server] = movingAverage(this.latencies[server], latency)
+}
+
+// discoverServer records a server which a locator returned, so that it remains a candidate when the
+// pool has no connections to it. Servers added with AddServer are already known.
+// MUST hold the pool lock when calling
+func (this *Pool) discoverServer(host string, port int) {
+	if host == "" {
+		return
+	}
+
+	for _, p := range this.providers {
+		if s, ok := p.(*serverConnectionProvider); ok && s.host == host && s.port == port {
+			return
+		}
+	}
+
+	for _, s := range this.discovered {
+		if s.host == host && s.port == port {
+			return
+		}
+	}
+
+	this.discovered = append(this.discovered, &serverConnectionProvider{
+		host:      host,
+		port:      port,
+		health:    this.health,
+		transport: this.transport,
+	})
+}
+
+// serverCandidates returns the servers which may be used for the given group along with their
+// current load. Every operation in progress counts towards a server's load, but only idle
+// connections for the given identity are counted as idle. Servers which are quarantined after a
+// recent failure are only included if there are idle connections to them.
+//
+// Every server a locator has returned is a candidate for operations outside a server group, even
+// if the pool holds no connections to it. New connections to such a server are made to it
+// directly.
+//
+// The returned slices are reused by the next call, so they are only valid until the pool lock is
+// released.
+// MUST hold the pool lock when calling
+func (this *Pool) serverCandidates(group string, identity *Identity) ([]ServerLoad, []serverCandidate) {
+	loads := this.loadScratch[:0]
+	candidates := this.candidateScratch[:0]
+
+	// There are few servers, so a linear search is cheaper than building an index
+	add := func(server, host string, port int) int {
+		for i := range loads {
+			if loads[i].Server == server {
+				return i
+			}
+		}
+
+		loads = append(loads, ServerLoad{
+			Server:  server,
+			Latency: this.latencies[server],
+		})
+		candidates = append(candidates, serverCandidate{
+			host: host,
+			port: port,
+		})
+
+		return len(loads) - 1
+	}
+
+	for _, c := range this.recentConnections {
+		if group != "" && c.serverGroup != group {
+			continue
+		}
+
+		i := add(c.serverAddress(), c.host, c.port)
+		if c.inUse {
+			loads[i].InFlight += 1
+		} else if c.usableFor(group, identity) {
+			loads[i].Idle += 1
+		}
+	}
+
+	if group == "" {
+		connected := len(loads)
+		for _, s := range this.discovered {
+			if i := add(s.address(), s.host, s.port); i >= connected {
+				candidates[i].provider = s
+			}
+		}
+
+		for _, p := range this.providers {
+			if s, ok := p.(*serverConnectionProvider); ok {
+				i := add(s.address(), s.host, s.port)
+				candidates[i].provider = s
+			}
+		}
+	}
+
+	n := 0
+	for i := range loads {
+		c := candidates[i]
+		if loads[i].Idle == 0 && c.host != "" && this.health.isQuarantined(c.host, c.port) {
+			continue
+		}
+
+		loads[n] = loads[i]
+		candidates[n] = c
+		n += 1
+	}
+
+	this.loadScratch = loads
+	this.candidateScratch = candidates
+
+	return loads[:n], candidates[:n]
+}
+
+// connectionProvider returns the provider used to create a new connection to the candidate, or
+// nil if the pool's providers should be used in turn. Servers discovered through a locator which
+// the pool already holds connections to are not connected to directly; the locator is asked again
+// instead, so that it can spread connections across the cluster and new connections can reach
+// servers which have joined it since. Connections for a server group are always obtained from the
+// locator.
+// MUST hold the pool lock when calling
+func (this *Pool) connectionProvider(candidate serverCandidate, group string) ConnectionProvider {
+	if candidate.provider != nil {
+		return candidate.provider
+	}
+
+	if group == "" && candidate.host != "" && this.locator != nil {
+		return this.locator
+	}
+
+	return nil
+}
+
+// chooseServer asks the load balancer to choose between the given servers.
+// MUST hold the pool lock when calling
+func (this *Pool) chooseServer(loads []ServerLoad) int {
+	i := this.balancer.Choose(loads)
+	if i < 0 || i >= len(loads) {
+		return 0
+	}
+
+	return i
+}
+
+// chooseConnection returns a connection to the server chosen by the load balancer. This is an
+// idle connection to that server if one exists, otherwise a new connection if the pool has space
+// for it, otherwise any idle connection. If fresh is true, idle connections are never used. If no
+// connection is available nil is returned. The returned flag is true if the connection was newly
+// created.
+// MUST hold the pool lock when calling
+func (this *Pool) chooseConnection(ctx context.Context, group string, identity *Identity, fresh bool) (*GeodeConnection, bool, error) {
+	var preferred ConnectionProvider
+
+	loads, candidates := this.serverCandidates(group, identity)
+	if len(loads) > 0 {
+		i := this.chooseServer(loads)
+		if loads[i].Idle > 0 && !fresh {
+			return this.idleConnectionTo(group, identity, loads[i].Server), false, nil
+		}
+		preferred = this.connectionProvider(candidates[i], group)
+	}
+
+	if !this.hasCapacity() {
+		if fresh {
+			return nil, false, nil
+		}
+		return this.idleConnection(group, identity), false, nil
+	}
+
+	gConn, err := this.createConnection(ctx, group, identity, preferred)
+	if err != nil {
+		// Prefer an idle connection to another server over failing
+		if idle := this.idleConnection(group, identity); idle != nil && !fresh {
+			return idle, false, nil
+		}
+		return nil, false, err
+	}
+
+	return gConn, true, nil
+}
+
+// chooseProvider returns the provider for the server chosen by the load balancer, or nil if the
+// servers are not yet known.
+// MUST hold the pool lock when calling
+func (this *Pool) chooseProvider(group string) ConnectionProvider {
+	loads, candidates := this.serverCandidates(group, nil)
+	if len(loads) == 0 {
+		return nil
+	}
+
+	return this.connectionProvider(candidates[this.chooseServer(loads)], group)
+}
@@ -0,0 +1,100 @@
+package connector
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultLivenessTimeout = 5 * time.Second
+
+// SetValidateOnBorrow determines whether idle connections are checked before being handed out.
+// The check is cheap and detects connections which the server has closed or reset, such as when
+// a server is restarted; those connections are discarded and another is used instead.
+func (this *Pool) SetValidateOnBorrow(validate bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.validateOnBorrow = validate
+}
+
+// SetLivenessCheck periodically sends a request to the server on each connection which has been
+// idle for the given interval. Connections which do not respond within the timeout are closed.
+// This detects connections which were dropped silently, for example by a firewall, before they
+// are used for a real operation. A timeout of 0 uses a default of 5 seconds, and an interval of
+// 0, the default, disables liveness checks.
+func (this *Pool) SetLivenessCheck(interval, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultLivenessTimeout
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	this.livenessInterval = interval
+	this.livenessTimeout = timeout
+	this.startReaper()
+}
+
+// checkLiveness pings idle connections which have not been used or checked within the liveness
+// interval, and discards those which do not respond.
+func (this *Pool) checkLiveness() {
+	var checking []*GeodeConnection
+
+	this.Lock()
+	interval, timeout := this.livenessInterval, this.livenessTimeout
+	if interval <= 0 || this.closed {
+		this.Unlock()
+		return
+	}
+
+	now := time.Now()
+	for _, c := range this.recentConnections {
+		if c.inUse || !c.handshakeDone || now.Sub(c.lastUsed) < interval || now.Sub(c.lastChecked) < interval {
+			continue
+		}
+
+		// Stop the connection being handed out while it is checked
+		c.inUse = true
+		checking = append(checking, c)
+	}
+	this.Unlock()
+
+	if len(checking) == 0 {
+		return
+	}
+
+	// Checking connections involves talking to the server, so do not hold the lock. They are
+	// checked concurrently, so that a dropped connection does not delay the checks of the others.
+	failed := make([]bool, len(checking))
+	var wg sync.WaitGroup
+	for i, c := range checking {
+		wg.Add(1)
+		go func(i int, c *GeodeConnection) {
+			defer wg.Done()
+			failed[i] = c.ping(timeout) != nil
+			c.lastChecked = time.Now()
+		}(i, c)
+	}
+	wg.Wait()
+
+	var disconnecting []*GeodeConnection
+
+	this.Lock()
+	for i, c := range checking {
+		c.inUse = false
+
+		if failed[i] {
+			this.discardConnection(c)
+		} else if this.closed && this.removeConnection(c) {
+			this.metrics.ConnectionDiscarded()
+			disconnecting = append(disconnecting, c)
+		}
+	}
+	this.notifyAvailable()
+	this.fillIdleConnections()
+	this.Unlock()
+
+	for _, c := range disconnecting {
+		c.disconnect(shutdownReason)
+	}
+}
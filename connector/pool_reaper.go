@@ -0,0 +1,145 @@
+package connector
+
+import (
+	"time"
+)
+
+// The reason given to servers when connections are closed because they are too old.
+const lifetimeExceededReason = "connection lifetime exceeded"
+
+// SetIdleTimeout closes connections which have not been used for longer than the given duration.
+// Idle connections are not closed if doing so would leave the pool with fewer than the minimum
+// number of idle connections. A value of 0, the default, disables idle eviction.
+func (this *Pool) SetIdleTimeout(timeout time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.idleTimeout = timeout
+	this.startReaper()
+}
+
+// SetMaxConnectionLifetime closes connections which were created longer ago than the given
+// duration. Connections which are in use are closed when they are returned to the pool. Limiting
+// the lifetime of connections allows load to rebalance when servers are restarted or added. A
+// value of 0, the default, means that connections may live forever.
+func (this *Pool) SetMaxConnectionLifetime(lifetime time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.maxLifetime = lifetime
+	this.startReaper()
+}
+
+// SetDisconnectOnEviction determines whether a DisconnectClientRequest is sent to the server
+// before a connection is closed due to idleness or age, so that the server knows the client
+// closed the connection deliberately.
+func (this *Pool) SetDisconnectOnEviction(disconnect bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.disconnectOnEviction = disconnect
+}
+
+// MUST hold the pool lock when calling
+func (this *Pool) reaperInterval() time.Duration {
+	interval := time.Duration(0)
+	for _, d := range []time.Duration{this.idleTimeout, this.maxLifetime, this.livenessInterval} {
+		if d > 0 && (interval <= 0 || d < interval) {
+			interval = d
+		}
+	}
+
+	return interval / 2
+}
+
+// MUST hold the pool lock when calling
+func (this *Pool) startReaper() {
+	if this.reaping || this.reaperInterval() <= 0 {
+		return
+	}
+
+	this.reaping = true
+	go this.reap()
+}
+
+func (this *Pool) reap() {
+	for {
+		this.Lock()
+		interval := this.reaperInterval()
+		if interval <= 0 || this.closed {
+			this.reaping = false
+			this.Unlock()
+			return
+		}
+		this.Unlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+			this.evictConnections()
+			this.checkLiveness()
+		case <-this.shutdown:
+			timer.Stop()
+		}
+	}
+}
+
+// evictConnections removes any idle connections which have exceeded the idle timeout or their
+// maximum lifetime from the pool and closes them.
+func (this *Pool) evictConnections() {
+	var evicted []*GeodeConnection
+	var reasons []string
+
+	this.Lock()
+	now := time.Now()
+	idle := this.idleCount()
+
+	for i := 0; i < len(this.recentConnections); i++ {
+		c := this.recentConnections[i]
+		if c.inUse {
+			continue
+		}
+
+		reason := ""
+		if this.maxLifetime > 0 && now.Sub(c.createdAt) > this.maxLifetime {
+			reason = lifetimeExceededReason
+		} else if this.idleTimeout > 0 && now.Sub(c.lastUsed) > this.idleTimeout && idle > this.minIdleConnections {
+			reason = "connection idle timeout"
+		}
+
+		if reason == "" {
+			continue
+		}
+
+		this.recentConnections = append(this.recentConnections[:i], this.recentConnections[i+1:]...)
+		i -= 1
+		idle -= 1
+
+		evicted = append(evicted, c)
+		reasons = append(reasons, reason)
+		this.metrics.ConnectionDiscarded()
+	}
+
+	disconnect := this.disconnectOnEviction
+	if len(evicted) > 0 {
+		this.notifyAvailable()
+		this.fillIdleConnections()
+	}
+	this.Unlock()
+
+	// Closing connections may involve talking to the server, so do not hold the lock
+	for i, c := range evicted {
+		closeEvicted(c, reasons[i], disconnect)
+	}
+}
+
+// closeEvicted closes a connection which has been evicted from the pool, first sending a
+// DisconnectClientRequest with the reason if the pool is configured to do so.
+// MUST NOT hold the pool lock when calling
+func closeEvicted(gConn *GeodeConnection, reason string, disconnect bool) {
+	if disconnect {
+		gConn.disconnect(reason)
+	} else {
+		_ = gConn.rawConn.Close()
+	}
+}
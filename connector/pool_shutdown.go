@@ -0,0 +1,175 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned when a connection is requested from a pool which has been closed.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// The reason given to servers when connections are closed because the pool is shutting down.
+const shutdownReason = "client shutting down"
+
+// Shutdown gracefully closes the pool. It immediately stops handing out connections, and then
+// waits for connections which are in use to be returned. Each connection is closed after sending
+// a DisconnectClientRequest, so that servers can tell a clean exit from a crash.
+//
+// Idle connections are disconnected concurrently, each being given until the earlier of the
+// context's deadline and a short timeout. If the context is done before all connections have been
+// disconnected or returned, the remaining connections are closed without waiting for them and the
+// context's error is returned. Calling Shutdown more than once is safe.
+func (this *Pool) Shutdown(ctx context.Context) error {
+	this.Lock()
+	if !this.closed {
+		this.closed = true
+		close(this.shutdown)
+	}
+
+	idle := this.removeConnections(false)
+	this.notifyAvailable()
+	this.Unlock()
+
+	if err := disconnectAll(ctx, idle); err != nil {
+		this.closeInUse()
+		return err
+	}
+
+	for {
+		this.Lock()
+		if len(this.recentConnections) == 0 && this.pending == 0 {
+			this.Unlock()
+			return nil
+		}
+		available := this.waitForAvailable()
+		this.Unlock()
+
+		select {
+		case <-available:
+		case <-ctx.Done():
+			this.closeInUse()
+			return ctx.Err()
+		}
+	}
+}
+
+// disconnectAll disconnects the given connections concurrently. Each one is given until the
+// earlier of the context's deadline and the usual disconnect timeout, after which any connection
+// which has not been disconnected is closed. The context's error is returned if the deadline
+// passed before all the connections were disconnected.
+func disconnectAll(ctx context.Context, conns []*GeodeConnection) error {
+	if len(conns) == 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(disconnectTimeout)
+	expires := false
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+		expires = true
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *GeodeConnection) {
+			defer wg.Done()
+			c.disconnectBy(shutdownReason, deadline)
+		}(c)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		// Closing a connection interrupts a server which is not responding
+		for _, c := range conns {
+			_ = c.rawConn.Close()
+		}
+
+		if expires {
+			// The timer may fire just before the context notices its deadline has passed
+			<-ctx.Done()
+		}
+
+		return ctx.Err()
+	}
+}
+
+// closeInUse removes the connections which are in use from the pool and closes them, causing
+// the operations using them to fail.
+func (this *Pool) closeInUse() {
+	this.Lock()
+	inUse := this.removeConnections(true)
+	this.Unlock()
+
+	for _, c := range inUse {
+		_ = c.rawConn.Close()
+	}
+}
+
+// Close closes the pool without waiting for connections which are in use. Idle connections are
+// disconnected as they are by Shutdown, but connections in use are closed immediately, causing
+// the operations using them to fail.
+func (this *Pool) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := this.Shutdown(ctx)
+	if err == context.Canceled {
+		return nil
+	}
+
+	return err
+}
+
+// retireConnection removes a connection from a pool which is shutting down and disconnects it.
+// MUST hold the pool lock when calling; it is released while disconnecting
+func (this *Pool) retireConnection(gConn *GeodeConnection) {
+	if !this.removeConnection(gConn) {
+		return
+	}
+
+	this.metrics.ConnectionDiscarded()
+	this.notifyAvailable()
+
+	// Disconnecting involves talking to the server, so do not hold the lock
+	this.Unlock()
+	gConn.disconnect(shutdownReason)
+	this.Lock()
+}
+
+// removeConnections removes either the idle connections, or those in use, from the pool and
+// returns them.
+// MUST hold the pool lock when calling
+func (this *Pool) removeConnections(inUse bool) []*GeodeConnection {
+	var removed []*GeodeConnection
+	var kept []*GeodeConnection
+
+	for _, c := range this.recentConnections {
+		if c.inUse == inUse {
+			removed = append(removed, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+
+	this.recentConnections = kept
+	for _, c := range removed {
+		this.releaseConnection(c)
+		this.metrics.ConnectionDiscarded()
+	}
+
+	return removed
+}
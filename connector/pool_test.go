@@ -0,0 +1,490 @@
+package connector_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pool", func() {
+
+	var pool *connector.Pool
+	var fakeConn *connectorfakes.FakeConn
+
+	BeforeEach(func() {
+		fakeConn = new(connectorfakes.FakeConn)
+		pool = connector.NewPool()
+		pool.AddConnection(fakeConn, true)
+	})
+
+	Context("Maximum connections", func() {
+		BeforeEach(func() {
+			pool.SetMaxConnections(1)
+		})
+
+		It("waits for a connection to be returned", func() {
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				pool.ReturnConnection(gConn)
+			}()
+
+			nextConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			Expect(nextConn).To(BeIdenticalTo(gConn))
+		})
+
+		It("times out waiting for a connection", func() {
+			pool.SetAcquireTimeout(50 * time.Millisecond)
+
+			_, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			_, err = pool.GetConnection()
+			Expect(err).To(MatchError("timed out waiting for a connection"))
+		})
+
+		It("does not wait when a connection is discarded", func() {
+			listener := startFakeGeodeMember(alwaysLocate(nil))
+			defer listener.Close()
+			pool.AddServer(hostAndPort(listener))
+
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				pool.DiscardConnection(gConn)
+			}()
+
+			nextConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			Expect(nextConn.GetRawConnection().RemoteAddr().String()).To(Equal(listener.Addr().String()))
+		})
+	})
+
+	Context("Idle connections", func() {
+		It("closes connections returned beyond the maximum idle", func() {
+			otherConn := new(connectorfakes.FakeConn)
+			pool.AddConnection(otherConn, true)
+			pool.SetMaxIdleConnections(1)
+
+			first, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			second, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			pool.ReturnConnection(first)
+			pool.ReturnConnection(second)
+
+			Expect(otherConn.CloseCallCount() + fakeConn.CloseCallCount()).To(Equal(1))
+		})
+
+		It("does not discard a connection again when it is returned after being discarded", func() {
+			metrics := &recordingMetrics{}
+			pool.SetMetrics(metrics)
+			pool.AddConnection(new(connectorfakes.FakeConn), true)
+			pool.AddConnection(new(connectorfakes.FakeConn), true)
+			pool.SetMaxIdleConnections(1)
+
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			pool.DiscardConnection(gConn)
+			pool.ReturnConnection(gConn)
+
+			Expect(gConn.GetRawConnection().(*connectorfakes.FakeConn).CloseCallCount()).To(Equal(1))
+			Expect(metrics.discarded).To(Equal(1))
+			Expect(metrics.active).To(Equal(0))
+		})
+
+		It("creates connections up to the minimum idle", func() {
+			rawListener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			listener := &countingListener{Listener: rawListener}
+			serveFakeGeodeListener(listener, alwaysLocate(nil))
+			defer listener.Close()
+
+			pool = connector.NewPool()
+			pool.SetMinIdleConnections(2)
+			pool.AddServer(hostAndPort(listener))
+
+			_, err = pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			Eventually(listener.Accepted).Should(BeEquivalentTo(3))
+			Consistently(listener.Accepted).Should(BeEquivalentTo(3))
+		})
+	})
+
+	Context("Eviction", func() {
+		It("closes connections which have been idle too long", func() {
+			pool.SetIdleTimeout(50 * time.Millisecond)
+
+			Eventually(fakeConn.CloseCallCount).Should(Equal(1))
+		})
+
+		It("keeps the minimum number of idle connections", func() {
+			pool.SetMinIdleConnections(1)
+			pool.SetIdleTimeout(20 * time.Millisecond)
+
+			Consistently(fakeConn.CloseCallCount, 100*time.Millisecond).Should(Equal(0))
+		})
+
+		It("closes connections which exceed their lifetime when returned", func() {
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			pool.SetMaxConnectionLifetime(time.Hour)
+			pool.ReturnConnection(gConn)
+			Expect(fakeConn.CloseCallCount()).To(Equal(0))
+
+			gConn, err = pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			pool.SetMaxConnectionLifetime(time.Nanosecond)
+			pool.ReturnConnection(gConn)
+			Expect(fakeConn.CloseCallCount()).To(Equal(1))
+		})
+
+		It("sends a DisconnectClientRequest before closing an evicted connection", func() {
+			fakeConn.ReadStub = func(b []byte) (int, error) {
+				response := &v1.Message{
+					MessageType: &v1.Message_DisconnectClientResponse{
+						DisconnectClientResponse: &v1.DisconnectClientResponse{},
+					},
+				}
+				return writeFakeMessage(response, b)
+			}
+
+			pool.SetDisconnectOnEviction(true)
+			pool.SetMaxConnectionLifetime(20 * time.Millisecond)
+
+			Eventually(fakeConn.CloseCallCount).Should(Equal(1))
+			Expect(fakeConn.WriteCallCount()).To(Equal(1))
+
+			request := &v1.Message{}
+			Expect(proto.NewBuffer(fakeConn.WriteArgsForCall(0)).DecodeMessage(request)).To(Succeed())
+			Expect(request.GetDisconnectClientRequest().GetReason()).To(Equal("connection lifetime exceeded"))
+		})
+
+		It("sends a DisconnectClientRequest before closing a connection which expires when returned", func() {
+			fakeConn.ReadStub = func(b []byte) (int, error) {
+				response := &v1.Message{
+					MessageType: &v1.Message_DisconnectClientResponse{
+						DisconnectClientResponse: &v1.DisconnectClientResponse{},
+					},
+				}
+				return writeFakeMessage(response, b)
+			}
+
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			pool.SetDisconnectOnEviction(true)
+			pool.SetMaxConnectionLifetime(time.Nanosecond)
+			pool.ReturnConnection(gConn)
+
+			Expect(fakeConn.CloseCallCount()).To(Equal(1))
+			Expect(fakeConn.WriteCallCount()).To(Equal(1))
+
+			request := &v1.Message{}
+			Expect(proto.NewBuffer(fakeConn.WriteArgsForCall(0)).DecodeMessage(request)).To(Succeed())
+			Expect(request.GetDisconnectClientRequest().GetReason()).To(Equal("connection lifetime exceeded"))
+		})
+	})
+
+	Context("Connecting", func() {
+		var release chan struct{}
+		var dialing *int32
+		var results chan error
+
+		// getConnection gets a connection in the background, reporting the result on results
+		getConnection := func() {
+			p := pool
+			go func() {
+				_, err := p.GetConnection()
+				results <- err
+			}()
+		}
+
+		// finish releases the dialer and waits for the n connections being got in the background
+		finish := func(n int) {
+			close(release)
+			for i := 0; i < n; i++ {
+				Expect(<-results).To(BeNil())
+			}
+		}
+
+		BeforeEach(func() {
+			released := make(chan struct{})
+			release = released
+			counter := new(int32)
+			dialing = counter
+			results = make(chan error, 2)
+
+			pool = connector.NewPool()
+			pool.SetDialer(connector.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				atomic.AddInt32(counter, 1)
+				<-released
+				client, server := net.Pipe()
+				go serveFakeGeodeMember(server, alwaysLocate(nil))
+				return client, nil
+			}))
+			pool.AddServer("slow", 1)
+		})
+
+		It("creates connections in parallel", func() {
+			getConnection()
+			getConnection()
+
+			Eventually(func() int32 { return atomic.LoadInt32(dialing) }).Should(BeEquivalentTo(2))
+			finish(2)
+		})
+
+		It("does not block other callers while connecting", func() {
+			getConnection()
+			Eventually(func() int32 { return atomic.LoadInt32(dialing) }).Should(BeEquivalentTo(1))
+
+			pool.AddConnection(fakeConn, true)
+			pool.SetLoadBalancer(&connector.LeastInFlight{})
+
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			Expect(gConn.GetRawConnection()).To(Equal(fakeConn))
+			finish(1)
+		})
+
+		It("counts connections being created towards the maximum", func() {
+			pool.SetMaxConnections(1)
+			pool.SetAcquireTimeout(50 * time.Millisecond)
+
+			getConnection()
+			Eventually(func() int32 { return atomic.LoadInt32(dialing) }).Should(BeEquivalentTo(1))
+
+			_, err := pool.GetConnection()
+			Expect(err).To(MatchError("timed out waiting for a connection"))
+			Expect(atomic.LoadInt32(dialing)).To(BeEquivalentTo(1))
+			finish(1)
+		})
+	})
+
+	Context("Health checks", func() {
+		It("discards idle connections closed by the server when validating on borrow", func() {
+			listener := startFakeGeodeMember(alwaysLocate(nil))
+			defer listener.Close()
+
+			pool = connector.NewPool()
+			pool.AddServer(hostAndPort(listener))
+
+			client, server := net.Pipe()
+			pool.AddConnection(client, true)
+			Expect(server.Close()).To(Succeed())
+
+			pool.SetValidateOnBorrow(true)
+
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			Expect(gConn.GetRawConnection().RemoteAddr().String()).To(Equal(listener.Addr().String()))
+		})
+
+		It("uses idle connections which are still open when validating on borrow", func() {
+			pool = connector.NewPool()
+
+			client, server := net.Pipe()
+			defer server.Close()
+			pool.AddConnection(client, true)
+
+			pool.SetValidateOnBorrow(true)
+
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+			Expect(gConn.GetRawConnection()).To(Equal(client))
+		})
+
+		It("closes idle connections which do not respond to a liveness check", func() {
+			fakeConn.ReadReturns(0, &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded})
+
+			pool.SetLivenessCheck(20*time.Millisecond, 20*time.Millisecond)
+
+			Eventually(fakeConn.CloseCallCount).Should(Equal(1))
+			request := &v1.Message{}
+			Expect(proto.NewBuffer(fakeConn.WriteArgsForCall(0)).DecodeMessage(request)).To(Succeed())
+			Expect(request.GetGetRegionNamesRequest()).NotTo(BeNil())
+		})
+
+		It("keeps idle connections which respond to a liveness check", func() {
+			fakeConn.ReadStub = func(b []byte) (int, error) {
+				response := &v1.Message{
+					MessageType: &v1.Message_GetRegionNamesResponse{
+						GetRegionNamesResponse: &v1.GetRegionNamesResponse{},
+					},
+				}
+				return writeFakeMessage(response, b)
+			}
+
+			pool.SetLivenessCheck(20*time.Millisecond, 20*time.Millisecond)
+
+			Eventually(fakeConn.WriteCallCount).Should(BeNumerically(">", 1))
+			Expect(fakeConn.CloseCallCount()).To(Equal(0))
+		})
+
+		It("checks other connections while one does not respond to a liveness check", func() {
+			stalled := make(chan struct{})
+			defer close(stalled)
+			fakeConn.ReadStub = func(b []byte) (int, error) {
+				<-stalled
+				return 0, io.EOF
+			}
+
+			responsive := new(connectorfakes.FakeConn)
+			responsive.ReadStub = func(b []byte) (int, error) {
+				response := &v1.Message{
+					MessageType: &v1.Message_GetRegionNamesResponse{
+						GetRegionNamesResponse: &v1.GetRegionNamesResponse{},
+					},
+				}
+				return writeFakeMessage(response, b)
+			}
+			pool.AddConnection(responsive, true)
+
+			// Both connections are due for a check by the time checks start
+			time.Sleep(40 * time.Millisecond)
+			pool.SetLivenessCheck(20*time.Millisecond, time.Second)
+
+			Eventually(responsive.WriteCallCount, 500*time.Millisecond).Should(Equal(1))
+			Expect(responsive.CloseCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("Shutdown", func() {
+		BeforeEach(func() {
+			fakeConn.ReadStub = func(b []byte) (int, error) {
+				response := &v1.Message{
+					MessageType: &v1.Message_DisconnectClientResponse{
+						DisconnectClientResponse: &v1.DisconnectClientResponse{},
+					},
+				}
+				return writeFakeMessage(response, b)
+			}
+		})
+
+		disconnectReason := func() string {
+			request := &v1.Message{}
+			Expect(proto.NewBuffer(fakeConn.WriteArgsForCall(0)).DecodeMessage(request)).To(Succeed())
+			return request.GetDisconnectClientRequest().GetReason()
+		}
+
+		It("disconnects idle connections and stops handing out connections", func() {
+			Expect(pool.Shutdown(context.Background())).To(Succeed())
+
+			Expect(fakeConn.CloseCallCount()).To(Equal(1))
+			Expect(fakeConn.WriteCallCount()).To(Equal(1))
+			Expect(disconnectReason()).To(Equal("client shutting down"))
+
+			_, err := pool.GetConnection()
+			Expect(err).To(Equal(connector.ErrPoolClosed))
+		})
+
+		It("waits for connections in use to be returned", func() {
+			gConn, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				pool.ReturnConnection(gConn)
+			}()
+
+			Expect(pool.Shutdown(context.Background())).To(Succeed())
+			Expect(fakeConn.CloseCallCount()).To(Equal(1))
+			Expect(disconnectReason()).To(Equal("client shutting down"))
+		})
+
+		It("closes connections still in use when the context is done", func() {
+			_, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			Expect(pool.Shutdown(ctx)).To(Equal(context.DeadlineExceeded))
+			Expect(fakeConn.CloseCallCount()).To(Equal(1))
+			Expect(fakeConn.WriteCallCount()).To(Equal(0))
+		})
+
+		It("stops waiting for idle connections to disconnect when the context is done", func() {
+			stalled := make(chan struct{})
+			defer close(stalled)
+
+			var stalledConns []*connectorfakes.FakeConn
+			for i := 0; i < 4; i++ {
+				c := new(connectorfakes.FakeConn)
+				c.ReadStub = func(b []byte) (int, error) {
+					<-stalled
+					return 0, net.ErrClosed
+				}
+				pool.AddConnection(c, true)
+				stalledConns = append(stalledConns, c)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			Expect(pool.Shutdown(ctx)).To(Equal(context.DeadlineExceeded))
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+
+			Expect(fakeConn.CloseCallCount()).To(BeNumerically(">=", 1))
+			for _, c := range stalledConns {
+				Expect(c.WriteCallCount()).To(Equal(1))
+				Expect(c.CloseCallCount()).To(BeNumerically(">=", 1))
+			}
+		})
+
+		It("wakes callers waiting for a connection", func() {
+			pool.SetMaxConnections(1)
+			_, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			errs := make(chan error, 1)
+			go func() {
+				_, err := pool.GetConnection()
+				errs <- err
+			}()
+
+			time.Sleep(20 * time.Millisecond)
+			Expect(pool.Close()).To(Succeed())
+			Eventually(errs).Should(Receive(Equal(connector.ErrPoolClosed)))
+		})
+	})
+})
+
+type countingListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (this *countingListener) Accept() (net.Conn, error) {
+	c, err := this.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&this.accepted, 1)
+	}
+
+	return c, err
+}
+
+func (this *countingListener) Accepted() int32 {
+	return atomic.LoadInt32(&this.accepted)
+}
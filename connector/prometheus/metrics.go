@@ -0,0 +1,151 @@
+// Package prometheus records the measurements of a connector.Pool as Prometheus metrics.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "geode_client"
+
+// Metrics implements connector.Metrics using Prometheus collectors.
+type Metrics struct {
+	operations           *prom.CounterVec
+	errors               *prom.CounterVec
+	latencies            *prom.HistogramVec
+	bytesSent            prom.Counter
+	bytesReceived        prom.Counter
+	connectionWait       prom.Histogram
+	connectionsCreated   prom.Counter
+	connectionsDiscarded prom.Counter
+	activeConnections    prom.Gauge
+}
+
+// NewMetrics creates Metrics and registers its collectors with the given registerer. When several
+// pools are measured, each must be given distinct constant labels, such as the name of the
+// cluster it connects to.
+func NewMetrics(registerer prom.Registerer, constLabels prom.Labels) (*Metrics, error) {
+	buckets := make([]float64, len(connector.DefaultLatencyBuckets))
+	for i, bucket := range connector.DefaultLatencyBuckets {
+		buckets[i] = bucket.Seconds()
+	}
+
+	this := &Metrics{
+		operations: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   namespace,
+			Name:        "operations_total",
+			Help:        "Operations performed, including those which failed.",
+			ConstLabels: constLabels,
+		}, []string{"operation"}),
+		errors: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   namespace,
+			Name:        "operation_errors_total",
+			Help:        "Operations which failed, by the error code returned by the server.",
+			ConstLabels: constLabels,
+		}, []string{"operation", "code"}),
+		latencies: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "operation_duration_seconds",
+			Help:        "Time taken by operations, including retries.",
+			Buckets:     buckets,
+			ConstLabels: constLabels,
+		}, []string{"operation"}),
+		bytesSent: prom.NewCounter(prom.CounterOpts{
+			Namespace:   namespace,
+			Name:        "sent_bytes_total",
+			Help:        "Bytes sent to servers.",
+			ConstLabels: constLabels,
+		}),
+		bytesReceived: prom.NewCounter(prom.CounterOpts{
+			Namespace:   namespace,
+			Name:        "received_bytes_total",
+			Help:        "Bytes received from servers.",
+			ConstLabels: constLabels,
+		}),
+		connectionWait: prom.NewHistogram(prom.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "connection_wait_seconds",
+			Help:        "Time taken to obtain a connection from the pool.",
+			Buckets:     buckets,
+			ConstLabels: constLabels,
+		}),
+		connectionsCreated: prom.NewCounter(prom.CounterOpts{
+			Namespace:   namespace,
+			Name:        "connections_created_total",
+			Help:        "Connections opened to servers.",
+			ConstLabels: constLabels,
+		}),
+		connectionsDiscarded: prom.NewCounter(prom.CounterOpts{
+			Namespace:   namespace,
+			Name:        "connections_discarded_total",
+			Help:        "Connections closed and removed from the pool.",
+			ConstLabels: constLabels,
+		}),
+		activeConnections: prom.NewGauge(prom.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "active_connections",
+			Help:        "Connections currently in use.",
+			ConstLabels: constLabels,
+		}),
+	}
+
+	collectors := []prom.Collector{
+		this.operations,
+		this.errors,
+		this.latencies,
+		this.bytesSent,
+		this.bytesReceived,
+		this.connectionWait,
+		this.connectionsCreated,
+		this.connectionsDiscarded,
+		this.activeConnections,
+	}
+
+	for _, c := range collectors {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return this, nil
+}
+
+func (this *Metrics) OperationCompleted(operation string, duration time.Duration, err error, code v1.ErrorCode) {
+	this.operations.WithLabelValues(operation).Inc()
+	if err != nil {
+		this.errors.WithLabelValues(operation, connector.ErrorCodeLabel(code)).Inc()
+	}
+
+	this.latencies.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+func (this *Metrics) BytesSent(n int) {
+	this.bytesSent.Add(float64(n))
+}
+
+func (this *Metrics) BytesReceived(n int) {
+	this.bytesReceived.Add(float64(n))
+}
+
+func (this *Metrics) ConnectionWait(duration time.Duration) {
+	this.connectionWait.Observe(duration.Seconds())
+}
+
+func (this *Metrics) ConnectionCreated() {
+	this.connectionsCreated.Inc()
+}
+
+func (this *Metrics) ConnectionDiscarded() {
+	this.connectionsDiscarded.Inc()
+}
+
+func (this *Metrics) ConnectionAcquired() {
+	this.activeConnections.Inc()
+}
+
+func (this *Metrics) ConnectionReleased() {
+	this.activeConnections.Dec()
+}
@@ -0,0 +1,86 @@
+package prometheus_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector/prometheus"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Metrics", func() {
+
+	var registry *prom.Registry
+	var metrics *prometheus.Metrics
+
+	BeforeEach(func() {
+		var err error
+		registry = prom.NewRegistry()
+		metrics, err = prometheus.NewMetrics(registry, prom.Labels{"cluster": "test"})
+		Expect(err).To(BeNil())
+	})
+
+	It("counts operations and errors by code", func() {
+		metrics.OperationCompleted("Get", time.Millisecond, nil, v1.ErrorCode_INVALID_ERROR_CODE)
+		metrics.OperationCompleted("Get", time.Millisecond, errors.New("denied"), v1.ErrorCode_AUTHORIZATION_FAILED)
+
+		expected := `
+# HELP geode_client_operation_errors_total Operations which failed, by the error code returned by the server.
+# TYPE geode_client_operation_errors_total counter
+geode_client_operation_errors_total{cluster="test",code="AUTHORIZATION_FAILED",operation="Get"} 1
+# HELP geode_client_operations_total Operations performed, including those which failed.
+# TYPE geode_client_operations_total counter
+geode_client_operations_total{cluster="test",operation="Get"} 2
+`
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(expected),
+			"geode_client_operations_total", "geode_client_operation_errors_total")).To(Succeed())
+
+		families, err := registry.Gather()
+		Expect(err).To(BeNil())
+
+		var durations uint64
+		for _, family := range families {
+			if family.GetName() == "geode_client_operation_duration_seconds" {
+				durations = family.GetMetric()[0].GetHistogram().GetSampleCount()
+			}
+		}
+		Expect(durations).To(Equal(uint64(2)))
+	})
+
+	It("tracks connections and bytes", func() {
+		metrics.ConnectionCreated()
+		metrics.ConnectionAcquired()
+		metrics.ConnectionAcquired()
+		metrics.ConnectionReleased()
+		metrics.BytesSent(10)
+		metrics.BytesReceived(20)
+
+		expected := `
+# HELP geode_client_active_connections Connections currently in use.
+# TYPE geode_client_active_connections gauge
+geode_client_active_connections{cluster="test"} 1
+# HELP geode_client_connections_created_total Connections opened to servers.
+# TYPE geode_client_connections_created_total counter
+geode_client_connections_created_total{cluster="test"} 1
+# HELP geode_client_received_bytes_total Bytes received from servers.
+# TYPE geode_client_received_bytes_total counter
+geode_client_received_bytes_total{cluster="test"} 20
+# HELP geode_client_sent_bytes_total Bytes sent to servers.
+# TYPE geode_client_sent_bytes_total counter
+geode_client_sent_bytes_total{cluster="test"} 10
+`
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(expected),
+			"geode_client_active_connections", "geode_client_connections_created_total",
+			"geode_client_received_bytes_total", "geode_client_sent_bytes_total")).To(Succeed())
+	})
+
+	It("cannot be registered twice with the same labels", func() {
+		_, err := prometheus.NewMetrics(registry, prom.Labels{"cluster": "test"})
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -1,15 +1,15 @@
 package connector
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
 	"github.com/gemfire/geode-go-client/query"
 	"github.com/golang/protobuf/proto"
-	"io"
-	"net"
 	"reflect"
+	"time"
 )
 
 //go:generate protoc --proto_path=$GEODE_CHECKOUT/geode-protobuf-messages/src/main/proto --go_out=../protobuf protocolVersion.proto
@@ -18,7 +18,12 @@ import (
 // A Protobuf connector provides the low-level interface between a Client and the backend Geode servers.
 // It should not be used directly; rather the Client API should be used.
 type Protobuf struct {
-	pool *Pool
+	pool         *Pool
+	serverGroup  string
+	identity     *Identity
+	retryPolicy  RetryPolicy
+	replayAll    bool
+	interceptors []Interceptor
 }
 
 const MAJOR_VERSION uint32 = 1
@@ -32,36 +37,149 @@ func (e *RetryableError) Error() string {
 	return e.Err.Error()
 }
 
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// An OutcomeUnknownError is returned when an operation which is not safe to replay, such as
+// PutIfAbsent or a function execution, has been sent but no response was received, for example
+// because the connection failed or the context ended. The server may or may not have applied the
+// operation. Err holds the cause.
+type OutcomeUnknownError struct {
+	Err error
+}
+
+func (e *OutcomeUnknownError) Error() string {
+	return fmt.Sprintf("outcome of operation is unknown: %s", e.Err.Error())
+}
+
+func (e *OutcomeUnknownError) Unwrap() error {
+	return e.Err
+}
+
 func NewConnector(pool *Pool) *Protobuf {
 	return &Protobuf{
-		pool: pool,
+		pool:        pool,
+		retryPolicy: DefaultRetryPolicy,
 	}
 }
 
+// WithServerGroup returns a connector which shares this connector's pool and settings but
+// performs all operations against servers in the given server group.
+func (this *Protobuf) WithServerGroup(group string) *Protobuf {
+	c := *this
+	c.serverGroup = group
+
+	return &c
+}
+
+// WithIdentity returns a connector which shares this connector's pool and settings but performs
+// all operations as the given identity, using connections which were authenticated with its
+// credentials rather than the pool's. Operations fail with ErrUnnamedIdentity if the identity has
+// no name, and with ErrIdentityWithoutCredentials if it has no credentials.
+func (this *Protobuf) WithIdentity(identity *Identity) *Protobuf {
+	c := *this
+	c.identity = identity
+
+	return &c
+}
+
+// SetRetryPolicy sets the policy which determines whether, and when, operations which fail with
+// a RetryableError are attempted again. By default DefaultRetryPolicy is used.
+func (this *Protobuf) SetRetryPolicy(policy RetryPolicy) {
+	this.retryPolicy = policy
+}
+
+// SetReplayNonIdempotent determines whether operations which are not safe to replay, such as
+// PutIfAbsent and function executions, are retried when the connection fails after the request
+// was sent. By default they are not, and an OutcomeUnknownError is returned instead.
+func (this *Protobuf) SetReplayNonIdempotent(replay bool) {
+	this.replayAll = replay
+}
+
+// Shutdown gracefully closes the connector's pool. See Pool.Shutdown.
+func (this *Protobuf) Shutdown(ctx context.Context) error {
+	return this.pool.Shutdown(ctx)
+}
+
+// Close closes the connector's pool without waiting for operations in progress. See Pool.Close.
+func (this *Protobuf) Close() error {
+	return this.pool.Close()
+}
+
+// operationName returns the name of the operation a request performs, such as "Get".
+func operationName(request *v1.Message) string {
+	switch request.MessageType.(type) {
+	case *v1.Message_PutRequest:
+		return "Put"
+	case *v1.Message_GetRequest:
+		return "Get"
+	case *v1.Message_PutAllRequest:
+		return "PutAll"
+	case *v1.Message_GetAllRequest:
+		return "GetAll"
+	case *v1.Message_RemoveRequest:
+		return "Remove"
+	case *v1.Message_GetServerRequest:
+		return "GetServer"
+	case *v1.Message_GetRegionNamesRequest:
+		return "GetRegionNames"
+	case *v1.Message_GetSizeRequest:
+		return "GetSize"
+	case *v1.Message_ExecuteFunctionOnRegionRequest:
+		return "ExecuteFunctionOnRegion"
+	case *v1.Message_ExecuteFunctionOnMemberRequest:
+		return "ExecuteFunctionOnMember"
+	case *v1.Message_HandshakeRequest:
+		return "Handshake"
+	case *v1.Message_ExecuteFunctionOnGroupRequest:
+		return "ExecuteFunctionOnGroup"
+	case *v1.Message_OqlQueryRequest:
+		return "OqlQuery"
+	case *v1.Message_KeySetRequest:
+		return "KeySet"
+	case *v1.Message_DisconnectClientRequest:
+		return "DisconnectClient"
+	case *v1.Message_ClearRequest:
+		return "Clear"
+	case *v1.Message_PutIfAbsentRequest:
+		return "PutIfAbsent"
+	}
+
+	return "Unknown"
+}
+
+// isIdempotent returns true if a request may safely be sent more than once.
+func isIdempotent(request *v1.Message) bool {
+	switch request.MessageType.(type) {
+	case *v1.Message_GetRequest,
+		*v1.Message_GetAllRequest,
+		*v1.Message_PutRequest,
+		*v1.Message_PutAllRequest,
+		*v1.Message_RemoveRequest,
+		*v1.Message_ClearRequest,
+		*v1.Message_GetSizeRequest,
+		*v1.Message_GetRegionNamesRequest,
+		*v1.Message_KeySetRequest,
+		*v1.Message_OqlQueryRequest:
+		return true
+	}
+
+	return false
+}
+
 func (this *Protobuf) Put(region string, k, v interface{}) (err error) {
-	key, err := EncodeValue(k)
-	if err != nil {
-		return err
-	}
+	return this.PutContext(context.Background(), region, k, v)
+}
 
-	value, err := EncodeValue(v)
+func (this *Protobuf) PutContext(ctx context.Context, region string, k, v interface{}) (err error) {
+	put, err := newPutRequest(region, k, v, this.pool.valueSerializer())
 	if err != nil {
 		return err
 	}
+	defer put.release()
 
-	put := &v1.Message{
-		MessageType: &v1.Message_PutRequest{
-			PutRequest: &v1.PutRequest{
-				RegionName: region,
-				Entry: &v1.Entry{
-					Key:   key,
-					Value: value,
-				},
-			},
-		},
-	}
-
-	_, err = this.doOperation(put)
+	_, err = this.doOperation(ctx, &put.message)
 	if err != nil {
 		return err
 	}
@@ -70,12 +188,16 @@ func (this *Protobuf) Put(region string, k, v interface{}) (err error) {
 }
 
 func (this *Protobuf) PutIfAbsent(region string, k, v interface{}) (err error) {
-	key, err := EncodeValue(k)
+	return this.PutIfAbsentContext(context.Background(), region, k, v)
+}
+
+func (this *Protobuf) PutIfAbsentContext(ctx context.Context, region string, k, v interface{}) (err error) {
+	key, err := this.encodeValue(k)
 	if err != nil {
 		return err
 	}
 
-	value, err := EncodeValue(v)
+	value, err := this.encodeValue(v)
 	if err != nil {
 		return err
 	}
@@ -92,7 +214,7 @@ func (this *Protobuf) PutIfAbsent(region string, k, v interface{}) (err error) {
 		},
 	}
 
-	_, err = this.doOperation(put)
+	_, err = this.doOperation(ctx, put)
 	if err != nil {
 		return err
 	}
@@ -101,28 +223,24 @@ func (this *Protobuf) PutIfAbsent(region string, k, v interface{}) (err error) {
 }
 
 func (this *Protobuf) Get(region string, k interface{}, value interface{}) (interface{}, error) {
-	key, err := EncodeValue(k)
+	return this.GetContext(context.Background(), region, k, value)
+}
+
+func (this *Protobuf) GetContext(ctx context.Context, region string, k interface{}, value interface{}) (interface{}, error) {
+	get, err := newGetRequest(region, k, this.pool.valueSerializer())
 	if err != nil {
 		return nil, err
 	}
+	defer get.release()
 
-	get := &v1.Message{
-		MessageType: &v1.Message_GetRequest{
-			GetRequest: &v1.GetRequest{
-				RegionName: region,
-				Key:        key,
-			},
-		},
-	}
-
-	response, err := this.doOperation(get)
+	response, err := this.doOperation(ctx, &get.message)
 	if err != nil {
 		return nil, err
 	}
 
 	v := response.GetGetResponse().GetResult()
 
-	decoded, err := DecodeValue(v, value)
+	decoded, err := this.decodeValue(v, value)
 	if err != nil {
 		return nil, err
 	}
@@ -131,22 +249,54 @@ func (this *Protobuf) Get(region string, k interface{}, value interface{}) (inte
 }
 
 func (this *Protobuf) GetAll(region string, keys interface{}) (map[interface{}]interface{}, map[interface{}]error, error) {
+	return this.GetAllContext(context.Background(), region, keys)
+}
+
+func (this *Protobuf) GetAllContext(ctx context.Context, region string, keys interface{}) (map[interface{}]interface{}, map[interface{}]error, error) {
+	getAll, _, err := this.newGetAllRequest(region, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := this.getAllBatch(ctx, region, nil, getAll.GetGetAllRequest().GetKey())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decodedEntries := make(map[interface{}]interface{})
+	for i, key := range result.Succeeded {
+		decodedEntries[key] = result.Values[i]
+	}
+
+	return decodedEntries, batchFailures(result), nil
+}
+
+// newGetAllRequest encodes the keys of a GetAll, which must be a slice or array, returning the
+// request and the keys in the order they were encoded.
+func (this *Protobuf) newGetAllRequest(region string, keys interface{}) (*v1.Message, []interface{}, error) {
 	keySlice := reflect.ValueOf(keys)
 	if keySlice.Kind() != reflect.Slice && keySlice.Kind() != reflect.Array {
 		return nil, nil, errors.New("keys must be a slice or array")
 	}
 
+	originalKeys := make([]interface{}, 0, keySlice.Len())
 	encodedKeys := make([]*v1.EncodedValue, 0, keySlice.Len())
 	for i := 0; i < keySlice.Len(); i++ {
-		key, err := EncodeValue(keySlice.Index(i).Interface())
+		k := keySlice.Index(i).Interface()
+		key, err := this.encodeValue(k)
 		if err != nil {
 			return nil, nil, err
 		}
 
+		originalKeys = append(originalKeys, k)
 		encodedKeys = append(encodedKeys, key)
 	}
 
-	getAll := &v1.Message{
+	return newGetAllMessage(region, encodedKeys), originalKeys, nil
+}
+
+func newGetAllMessage(region string, encodedKeys []*v1.EncodedValue) *v1.Message {
+	return &v1.Message{
 		MessageType: &v1.Message_GetAllRequest{
 			GetAllRequest: &v1.GetAllRequest{
 				RegionName:  region,
@@ -155,64 +305,61 @@ func (this *Protobuf) GetAll(region string, keys interface{}) (map[interface{}]i
 			},
 		},
 	}
+}
+
+func (this *Protobuf) PutAll(region string, entries interface{}) (map[interface{}]error, error) {
+	return this.PutAllContext(context.Background(), region, entries)
+}
 
-	response, err := this.doOperation(getAll)
+func (this *Protobuf) PutAllContext(ctx context.Context, region string, entries interface{}) (map[interface{}]error, error) {
+	putAll, _, err := this.newPutAllRequest(region, entries)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	decodedEntries := make(map[interface{}]interface{})
-	decodedFailures := make(map[interface{}]error)
-
-	for _, entry := range response.GetGetAllResponse().Entries {
-		key, err := DecodeValue(entry.Key, nil)
-		if err != nil {
-			return nil, nil, errors.New(fmt.Sprintf("unable to decode GetAll response key: %s", err.Error()))
-		}
-
-		value, err := DecodeValue(entry.Value, nil)
-		if err != nil {
-			decodedFailures[key] = errors.New(fmt.Sprintf("unable to decode GetAll value for key: %v: %s", key, err.Error()))
-			continue
-		}
-
-		decodedEntries[key] = value
+	result, err := this.putAllBatch(ctx, region, nil, putAll.GetPutAllRequest().GetEntry())
+	if err != nil {
+		return nil, err
 	}
 
-	for _, failure := range response.GetGetAllResponse().Failures {
-		key, err := DecodeValue(failure.Key, nil)
-		if err != nil {
-			return nil, nil, errors.New(fmt.Sprintf("unable to decode GetAll failure response for key: %v: %s", failure.Key, err.Error()))
-		}
+	return batchFailures(result), nil
+}
 
-		decodedFailures[key] = errors.New(fmt.Sprintf("%s (%d)", failure.Error.Message, failure.Error.ErrorCode))
+// batchFailures returns the error of each failed key of a batch, or nil if no keys failed.
+func batchFailures(result *BatchResult) map[interface{}]error {
+	if len(result.Failures) == 0 {
+		return nil
 	}
 
-	if len(decodedFailures) == 0 {
-		return decodedEntries, nil, nil
+	failures := make(map[interface{}]error)
+	for _, f := range result.Failures {
+		failures[f.Key] = f.Err
 	}
 
-	return decodedEntries, decodedFailures, nil
+	return failures
 }
 
-func (this *Protobuf) PutAll(region string, entries interface{}) (map[interface{}]error, error) {
+// newPutAllRequest encodes the entries of a PutAll, which must be a map, returning the request and
+// the keys in the order they were encoded.
+func (this *Protobuf) newPutAllRequest(region string, entries interface{}) (*v1.Message, []interface{}, error) {
 	// Check if we have a map
 	entriesMap := reflect.ValueOf(entries)
 	if entriesMap.Kind() != reflect.Map {
-		return nil, errors.New("entries must be a map")
+		return nil, nil, errors.New("entries must be a map")
 	}
 
-	encodedEntries := make([]*v1.Entry, 0)
+	originalKeys := make([]interface{}, 0, entriesMap.Len())
+	encodedEntries := make([]*v1.Entry, 0, entriesMap.Len())
 
 	for _, k := range entriesMap.MapKeys() {
-		key, err := EncodeValue(k.Interface())
+		key, err := this.encodeValue(k.Interface())
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		value, err := EncodeValue(entriesMap.MapIndex(k).Interface())
+		value, err := this.encodeValue(entriesMap.MapIndex(k).Interface())
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		e := &v1.Entry{
@@ -220,10 +367,15 @@ func (this *Protobuf) PutAll(region string, entries interface{}) (map[interface{
 			Value: value,
 		}
 
+		originalKeys = append(originalKeys, k.Interface())
 		encodedEntries = append(encodedEntries, e)
 	}
 
-	putAll := &v1.Message{
+	return newPutAllMessage(region, encodedEntries), originalKeys, nil
+}
+
+func newPutAllMessage(region string, encodedEntries []*v1.Entry) *v1.Message {
+	return &v1.Message{
 		MessageType: &v1.Message_PutAllRequest{
 			PutAllRequest: &v1.PutAllRequest{
 				RegionName: region,
@@ -231,32 +383,14 @@ func (this *Protobuf) PutAll(region string, entries interface{}) (map[interface{
 			},
 		},
 	}
-
-	r, err := this.doOperation(putAll)
-	if err != nil {
-		return nil, err
-	}
-
-	response := r.GetPutAllResponse()
-	failures := make(map[interface{}]error)
-	for _, k := range response.GetFailedKeys() {
-		key, err := DecodeValue(k.Key, nil)
-		if err != nil {
-			return nil, errors.New(fmt.Sprintf("unable to decode failed PutAll response key: %s", err.Error()))
-		}
-
-		failures[key] = errors.New(fmt.Sprintf("%s (%d)", k.GetError().Message, k.GetError().ErrorCode))
-	}
-
-	if len(failures) == 0 {
-		return nil, nil
-	}
-
-	return failures, nil
 }
 
 func (this *Protobuf) Remove(region string, k interface{}) error {
-	key, err := EncodeValue(k)
+	return this.RemoveContext(context.Background(), region, k)
+}
+
+func (this *Protobuf) RemoveContext(ctx context.Context, region string, k interface{}) error {
+	key, err := this.encodeValue(k)
 	if err != nil {
 		return err
 	}
@@ -270,12 +404,16 @@ func (this *Protobuf) Remove(region string, k interface{}) error {
 		},
 	}
 
-	_, err = this.doOperation(remove)
+	_, err = this.doOperation(ctx, remove)
 
 	return err
 }
 
 func (this *Protobuf) Size(r string) (int32, error) {
+	return this.SizeContext(context.Background(), r)
+}
+
+func (this *Protobuf) SizeContext(ctx context.Context, r string) (int32, error) {
 	request := &v1.Message{
 		MessageType: &v1.Message_GetSizeRequest{
 			GetSizeRequest: &v1.GetSizeRequest{
@@ -284,7 +422,7 @@ func (this *Protobuf) Size(r string) (int32, error) {
 		},
 	}
 
-	response, err := this.doOperation(request)
+	response, err := this.doOperation(ctx, request)
 	if err != nil {
 		return 0, err
 	}
@@ -295,7 +433,11 @@ func (this *Protobuf) Size(r string) (int32, error) {
 }
 
 func (this *Protobuf) ExecuteOnRegion(functionId, region string, functionArgs interface{}, keyFilter []interface{}) ([]interface{}, error) {
-	args, err := EncodeValue(functionArgs)
+	return this.ExecuteOnRegionContext(context.Background(), functionId, region, functionArgs, keyFilter)
+}
+
+func (this *Protobuf) ExecuteOnRegionContext(ctx context.Context, functionId, region string, functionArgs interface{}, keyFilter []interface{}) ([]interface{}, error) {
+	args, err := this.encodeValue(functionArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -310,17 +452,21 @@ func (this *Protobuf) ExecuteOnRegion(functionId, region string, functionArgs in
 		},
 	}
 
-	response, err := this.doOperation(request)
+	response, err := this.doOperation(ctx, request)
 	if err != nil {
 		return nil, err
 	}
 
 	results := response.GetExecuteFunctionOnRegionResponse().GetResults()
-	return decodedFunctionResults(results)
+	return this.decodedFunctionResults(results)
 }
 
 func (this *Protobuf) ExecuteOnMembers(functionId string, members []string, functionArgs interface{}) ([]interface{}, error) {
-	args, err := EncodeValue(functionArgs)
+	return this.ExecuteOnMembersContext(context.Background(), functionId, members, functionArgs)
+}
+
+func (this *Protobuf) ExecuteOnMembersContext(ctx context.Context, functionId string, members []string, functionArgs interface{}) ([]interface{}, error) {
+	args, err := this.encodeValue(functionArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -335,17 +481,21 @@ func (this *Protobuf) ExecuteOnMembers(functionId string, members []string, func
 		},
 	}
 
-	response, err := this.doOperation(request)
+	response, err := this.doOperation(ctx, request)
 	if err != nil {
 		return nil, err
 	}
 
 	results := response.GetExecuteFunctionOnMemberResponse().GetResults()
-	return decodedFunctionResults(results)
+	return this.decodedFunctionResults(results)
 }
 
 func (this *Protobuf) ExecuteOnGroups(functionId string, groups []string, functionArgs interface{}) ([]interface{}, error) {
-	args, err := EncodeValue(functionArgs)
+	return this.ExecuteOnGroupsContext(context.Background(), functionId, groups, functionArgs)
+}
+
+func (this *Protobuf) ExecuteOnGroupsContext(ctx context.Context, functionId string, groups []string, functionArgs interface{}) ([]interface{}, error) {
+	args, err := this.encodeValue(functionArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -360,23 +510,27 @@ func (this *Protobuf) ExecuteOnGroups(functionId string, groups []string, functi
 		},
 	}
 
-	response, err := this.doOperation(request)
+	response, err := this.doOperation(ctx, request)
 	if err != nil {
 		return nil, err
 	}
 
 	results := response.GetExecuteFunctionOnGroupResponse().GetResults()
-	return decodedFunctionResults(results)
+	return this.decodedFunctionResults(results)
 }
 
 func (this *Protobuf) QuerySingleResult(query *query.Query) (interface{}, error) {
-	response, err := this.doQuery(query.QueryString, query.BindParameters)
+	return this.QuerySingleResultContext(context.Background(), query)
+}
+
+func (this *Protobuf) QuerySingleResultContext(ctx context.Context, query *query.Query) (interface{}, error) {
+	response, err := this.doQuery(ctx, query.QueryString, query.BindParameters)
 	if err != nil {
 		return nil, err
 	}
 
 	ref := cloneStruct(query.Reference)
-	result, err := DecodeValue(response.GetOqlQueryResponse().GetSingleResult(), ref)
+	result, err := this.decodeValue(response.GetOqlQueryResponse().GetSingleResult(), ref)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("unable to decode query result: %s", err.Error()))
 	}
@@ -385,7 +539,11 @@ func (this *Protobuf) QuerySingleResult(query *query.Query) (interface{}, error)
 }
 
 func (this *Protobuf) QueryListResult(query *query.Query) ([]interface{}, error) {
-	response, err := this.doQuery(query.QueryString, query.BindParameters)
+	return this.QueryListResultContext(context.Background(), query)
+}
+
+func (this *Protobuf) QueryListResultContext(ctx context.Context, query *query.Query) ([]interface{}, error) {
+	response, err := this.doQuery(ctx, query.QueryString, query.BindParameters)
 	if err != nil {
 		return nil, err
 	}
@@ -396,7 +554,7 @@ func (this *Protobuf) QueryListResult(query *query.Query) ([]interface{}, error)
 
 	for i, v := range encodedResultList {
 		ref := cloneStruct(query.Reference)
-		val, err := DecodeValue(v, ref)
+		val, err := this.decodeValue(v, ref)
 		if err != nil {
 			return nil, errors.New(fmt.Sprintf("unable to decode query result: %s", err.Error()))
 		}
@@ -407,7 +565,11 @@ func (this *Protobuf) QueryListResult(query *query.Query) ([]interface{}, error)
 }
 
 func (this *Protobuf) QueryTableResult(query *query.Query) (map[string][]interface{}, error) {
-	response, err := this.doQuery(query.QueryString, query.BindParameters)
+	return this.QueryTableResultContext(context.Background(), query)
+}
+
+func (this *Protobuf) QueryTableResultContext(ctx context.Context, query *query.Query) (map[string][]interface{}, error) {
+	response, err := this.doQuery(ctx, query.QueryString, query.BindParameters)
 	if err != nil {
 		return nil, err
 	}
@@ -420,7 +582,7 @@ func (this *Protobuf) QueryTableResult(query *query.Query) (map[string][]interfa
 
 	for i, columnName := range columns {
 		ref := cloneStruct(query.Reference)
-		val, err := DecodeValueList(valueList[i], ref)
+		val, err := decodeValueList(valueList[i], ref, this.pool.valueSerializer())
 		if err != nil {
 			return nil, errors.New(fmt.Sprintf("unable to decode query result: %s", err.Error()))
 		}
@@ -439,10 +601,10 @@ func cloneStruct(i interface{}) interface{} {
 	return reflect.New(reflect.Indirect(reflect.ValueOf(i)).Type()).Interface()
 }
 
-func (this *Protobuf) doQuery(query string, bindParameters []interface{}) (*v1.Message, error) {
+func (this *Protobuf) doQuery(ctx context.Context, query string, bindParameters []interface{}) (*v1.Message, error) {
 	encodedKeys := make([]*v1.EncodedValue, 0, len(bindParameters))
 	for i := 0; i < len(bindParameters); i++ {
-		key, err := EncodeValue(bindParameters[i])
+		key, err := this.encodeValue(bindParameters[i])
 		if err != nil {
 			return nil, err
 		}
@@ -459,7 +621,7 @@ func (this *Protobuf) doQuery(query string, bindParameters []interface{}) (*v1.M
 		},
 	}
 
-	response, err := this.doOperation(request)
+	response, err := this.doOperation(ctx, request)
 	if err != nil {
 		return nil, err
 	}
@@ -467,11 +629,11 @@ func (this *Protobuf) doQuery(query string, bindParameters []interface{}) (*v1.M
 	return response, nil
 }
 
-func decodedFunctionResults(results []*v1.EncodedValue) ([]interface{}, error) {
+func (this *Protobuf) decodedFunctionResults(results []*v1.EncodedValue) ([]interface{}, error) {
 	decodedEntries := make([]interface{}, len(results))
 
 	for i, entry := range results {
-		value, err := DecodeValue(entry, nil)
+		value, err := this.decodeValue(entry, nil)
 		if err != nil {
 			return nil, errors.New(fmt.Sprintf("unable to decode function result value: %s", err.Error()))
 		}
@@ -482,121 +644,256 @@ func decodedFunctionResults(results []*v1.EncodedValue) ([]interface{}, error) {
 	return decodedEntries, nil
 }
 
-func (this *Protobuf) doOperation(request *v1.Message) (*v1.Message, error) {
-	gConn, err := this.pool.GetConnection()
+func (this *Protobuf) getConnection(ctx context.Context) (*GeodeConnection, error) {
+	return this.pool.GetConnectionAs(ctx, this.connectionGroup(), this.identity)
+}
+
+// getNewConnection is like getConnection but always creates a new connection, so that it is
+// authenticated with the current credentials.
+func (this *Protobuf) getNewConnection(ctx context.Context) (*GeodeConnection, error) {
+	return this.pool.getConnection(ctx, this.connectionGroup(), this.identity, true)
+}
+
+// connectionGroup returns the server group which operations are performed against.
+func (this *Protobuf) connectionGroup() string {
+	if this.serverGroup != "" {
+		return this.serverGroup
+	}
+
+	return this.pool.serverGroup
+}
+
+// doOperation performs an operation. See performOperation.
+func (this *Protobuf) doOperation(ctx context.Context, request *v1.Message) (*v1.Message, error) {
+	response, _, err := this.performOperation(ctx, request)
+	return response, err
+}
+
+// performOperation performs an operation, passing it through the connector's interceptors, and
+// records it in the pool's metrics and traces. The address of the server which responded is also
+// returned.
+func (this *Protobuf) performOperation(ctx context.Context, request *v1.Message) (*v1.Message, string, error) {
+	start := time.Now()
+	name := operationName(request)
+	region := operationRegion(request)
+	ctx, span := this.pool.startOperationSpan(ctx, name, region)
+
+	var response *v1.Message
+	var server string
+	var err error
+	if len(this.interceptors) == 0 {
+		response, server, err = this.retryOperation(ctx, request)
+	} else {
+		op := &Operation{
+			Name:    name,
+			Region:  region,
+			Request: request,
+			Start:   start,
+		}
+		response, err = this.intercept(ctx, op, this.interceptors)
+		server = op.Server
+	}
+
+	finishSpan(span, err)
+	this.pool.recordOperation(request, time.Since(start), err)
+
+	return response, server, err
+}
+
+// retryOperation performs an operation, retrying it according to the connector's retry policy if it
+// fails with a RetryableError. If more than one attempt is made and the operation ultimately
+// fails, a RetryError describing every attempt is returned. Operations which are not idempotent
+// are only retried if their request was not sent; otherwise an OutcomeUnknownError is returned.
+// The address of the server which responded is also returned.
+func (this *Protobuf) retryOperation(ctx context.Context, request *v1.Message) (*v1.Message, string, error) {
+	var attempts []Attempt
+	span := operationSpan(ctx)
+
+	for {
+		if span != nil {
+			span.SetAttribute(AttributeAttempt, len(attempts)+1)
+		}
+
+		message, server, err := this.attemptOperation(ctx, request)
+		if err == nil {
+			return message, server, nil
+		}
+
+		attempts = append(attempts, Attempt{
+			Server: server,
+			Err:    err,
+		})
+
+		retry := false
+		var backoff time.Duration
+		if _, ok := err.(*RetryableError); ok {
+			backoff, retry = this.retryPolicy.ShouldRetry(len(attempts), err)
+		}
+
+		if !retry {
+			if len(attempts) == 1 {
+				return nil, server, err
+			}
+			return nil, server, &RetryError{Attempts: attempts}
+		}
+
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, server, &RetryError{Attempts: attempts, Err: ctx.Err()}
+			}
+		}
+	}
+}
+
+// attemptOperation performs a single attempt of an operation, returning the response and the
+// address of the server the request was sent to.
+func (this *Protobuf) attemptOperation(ctx context.Context, request *v1.Message) (*v1.Message, string, error) {
+	gConn, err := this.getConnection(ctx)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	message, unanswered, err := this.sendRequest(ctx, gConn, request)
+	if isAuthenticationError(err) && this.pool.credentialsProvider(this.identity) != nil {
+		// The server no longer accepts the connection's credentials, which may have been rotated.
+		// It rejected the request without performing it, so even an operation which is not
+		// idempotent is sent again on a new connection authenticated with the current credentials.
+		this.pool.DiscardConnection(gConn)
+
+		gConn, err = this.getNewConnection(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+
+		message, unanswered, err = this.sendRequest(ctx, gConn, request)
 	}
 	defer this.pool.ReturnConnection(gConn)
 
-	message, err := doOperationWithConnection(gConn.rawConn, request)
+	if errors.Is(err, ErrUnsupportedOperation) {
+		err = &UnsupportedOperationError{
+			Operation: operationName(request),
+			Version:   gConn.serverVersion,
+			Err:       err,
+		}
+	}
+
 	if err != nil {
+		// The state of the connection is unknown, so it cannot be reused
 		this.pool.DiscardConnection(gConn)
+
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+
+		if unanswered && !this.replayAll && !isIdempotent(request) {
+			if r, ok := err.(*RetryableError); ok {
+				err = r.Err
+			}
+			err = &OutcomeUnknownError{Err: err}
+		}
 	}
 
-	if _, ok := err.(*RetryableError); ok {
-		return this.doOperation(request)
-	} else if err != nil {
-		return nil, err
+	return message, gConn.serverAddress(), err
+}
+
+// sendRequest sends a request on a connection and reads the response, recording the server's
+// latency and the sizes of the messages. See exchange.
+func (this *Protobuf) sendRequest(ctx context.Context, gConn *GeodeConnection, request *v1.Message) (*v1.Message, bool, error) {
+	span := operationSpan(ctx)
+	if span != nil {
+		span.SetAttribute(AttributeServer, gConn.serverAddress())
+		span.SetAttribute(AttributeRequestSize, proto.Size(request))
+	}
+
+	start := time.Now()
+	stopWatching := gConn.watchContext(ctx)
+	message, unanswered, err := exchange(gConn, request)
+	stopWatching()
+
+	if err == nil {
+		this.pool.recordLatency(gConn, time.Since(start))
+		if span != nil {
+			span.SetAttribute(AttributeResponseSize, proto.Size(message))
+		}
 	}
 
-	return message, nil
+	return message, unanswered, err
 }
 
-func doOperationWithConnection(connection net.Conn, request *v1.Message) (*v1.Message, error) {
-	err := writeMessage(connection, request)
+func doOperationWithConnection(gConn *GeodeConnection, request *v1.Message) (*v1.Message, error) {
+	response, _, err := exchange(gConn, request)
+	return response, err
+}
+
+// exchange sends a request and reads the server's response. unanswered is true if the request was
+// written but no response could be read, in which case the server may have applied it.
+func exchange(gConn *GeodeConnection, request *v1.Message) (response *v1.Message, unanswered bool, err error) {
+	err = gConn.send(request)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// In the event that the client connection has timed out, it would have been closed by the server.
 	// This results in a FIN being sent to the client, however the prior write may appear to have succeeded
 	// even in light of the server side of the connection being closed. It is only on a subsequent read
 	// that an error will be detected. See Stevens pg 132, Section 5.13 SIGPIPE signal.
-	response, err := readResponse(connection)
+	response, err = readResponse(gConn)
 	if err != nil {
 		if err.Error() == "EOF" {
-			return nil, &RetryableError{err}
+			return nil, true, &RetryableError{Err: err}
 		}
-		return nil, err
+		return nil, true, err
 	}
 
 	if x := response.GetErrorResponse(); x != nil {
-		return nil, errors.New(fmt.Sprintf("%s (%d)", x.GetError().Message, x.GetError().ErrorCode))
+		return nil, false, newServerError(x.GetError(), request, gConn.serverAddress())
 	}
 
-	return response, nil
-}
-
-func writeMessage(connection net.Conn, message proto.Message) (err error) {
-	p := proto.NewBuffer(nil)
-	err = p.EncodeMessage(message)
-	if err != nil {
-		return err
-	}
-
-	_, err = connection.Write(p.Bytes())
-	if err != nil {
-		switch nerr := err.(type) {
-		case *net.OpError:
-			if nerr.Op == "write" {
-				return &RetryableError{err}
-			}
-		}
-		return err
-	}
-
-	return nil
+	return response, false, nil
 }
 
-func readResponse(connection net.Conn) (*v1.Message, error) {
-	data, err := readRawMessage(connection)
+func readResponse(gConn *GeodeConnection) (*v1.Message, error) {
+	data, err := gConn.receive()
 	if err != nil {
 		return nil, err
 	}
 
-	p := proto.NewBuffer(data)
 	response := &v1.Message{}
-
-	if err := p.DecodeMessage(response); err != nil {
+	if err := proto.Unmarshal(data, response); err != nil {
 		return nil, err
 	}
 
 	return response, nil
 }
 
-func readRawMessage(connection net.Conn) ([]byte, error) {
-	data := make([]byte, 4096)
-	bytesRead, err := connection.Read(data)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get the length of the message
-	m, n := proto.DecodeVarint(data)
-	messageLength := int(m) + n
+// encodeValue encodes a value, using the pool's ValueSerializer for custom objects.
+func (this *Protobuf) encodeValue(val interface{}) (*v1.EncodedValue, error) {
+	return newEncodedValue(val, this.pool.valueSerializer())
+}
 
-	if messageLength > len(data) {
-		t := make([]byte, len(data), messageLength)
-		copy(t, data)
-		data = t
-	}
+// decodeValue decodes a value, using the pool's ValueSerializer for custom objects.
+func (this *Protobuf) decodeValue(value *v1.EncodedValue, ref interface{}) (interface{}, error) {
+	return decodeValue(value, ref, this.pool.valueSerializer())
+}
 
-	for bytesRead < messageLength {
-		n, err := io.ReadFull(connection, data[bytesRead:messageLength])
-		if err != nil {
-			return nil, err
-		}
+func EncodeValue(val interface{}) (*v1.EncodedValue, error) {
+	return newEncodedValue(val, nil)
+}
 
-		bytesRead += n
+func newEncodedValue(val interface{}, serializer ValueSerializer) (*v1.EncodedValue, error) {
+	ev := &v1.EncodedValue{}
+	if err := encodeValue(ev, val, serializer); err != nil {
+		return nil, err
 	}
 
-	return data[0:bytesRead], nil
+	return ev, nil
 }
 
-func EncodeValue(val interface{}) (*v1.EncodedValue, error) {
-	ev := &v1.EncodedValue{}
-
+func encodeValue(ev *v1.EncodedValue, val interface{}, serializer ValueSerializer) error {
 	switch k := val.(type) {
 	case int:
 		ev.Value = &v1.EncodedValue_IntResult{int32(k)}
@@ -616,23 +913,27 @@ func EncodeValue(val interface{}) (*v1.EncodedValue, error) {
 		ev.Value = &v1.EncodedValue_FloatResult{k}
 	case []byte:
 		ev.Value = &v1.EncodedValue_BinaryResult{k}
+	case CustomEncodedValue:
+		ev.Value = &v1.EncodedValue_CustomObjectResult{CustomObjectResult: k}
 	case string:
 		ev.Value = &v1.EncodedValue_StringResult{k}
 	default:
 		// <nil> is not a type
 		if k == nil {
 			ev.Value = &v1.EncodedValue_NullResult{}
+		} else if ok, err := serializeValue(ev, k, serializer); ok || err != nil {
+			return err
 		} else {
 			// Assume we have some struct and want to turn it into JSON
 			j, err := json.Marshal(k)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			ev.Value = &v1.EncodedValue_JsonObjectResult{string(j)}
 		}
 	}
 
-	return ev, nil
+	return nil
 }
 
 func EncodeList(list interface{}) ([]*v1.EncodedValue, error) {
@@ -687,6 +988,10 @@ func EncodeTable(table map[string][]interface{}) (*v1.Table, error) {
 }
 
 func DecodeValue(value *v1.EncodedValue, ref interface{}) (interface{}, error) {
+	return decodeValue(value, ref, nil)
+}
+
+func decodeValue(value *v1.EncodedValue, ref interface{}, serializer ValueSerializer) (interface{}, error) {
 	var decodedValue interface{}
 
 	switch v := value.GetValue().(type) {
@@ -715,6 +1020,12 @@ func DecodeValue(value *v1.EncodedValue, ref interface{}) (interface{}, error) {
 			return nil, err
 		}
 		decodedValue = ref
+	case *v1.EncodedValue_CustomObjectResult:
+		decoded, err := deserializeValue(v.CustomObjectResult, ref, serializer)
+		if err != nil {
+			return nil, err
+		}
+		decodedValue = decoded
 	case *v1.EncodedValue_NullResult, nil:
 		decodedValue = nil
 	default:
@@ -725,10 +1036,14 @@ func DecodeValue(value *v1.EncodedValue, ref interface{}) (interface{}, error) {
 }
 
 func DecodeValueList(list *v1.EncodedValueList, ref interface{}) ([]interface{}, error) {
+	return decodeValueList(list, ref, nil)
+}
+
+func decodeValueList(list *v1.EncodedValueList, ref interface{}, serializer ValueSerializer) ([]interface{}, error) {
 	decodedValueList := make([]interface{}, len(list.GetElement()))
 
 	for i, v := range list.GetElement() {
-		val, err := DecodeValue(v, ref)
+		val, err := decodeValue(v, ref, serializer)
 		if err != nil {
 			return nil, err
 		}
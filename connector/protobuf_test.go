@@ -12,6 +12,8 @@ import (
 	"strconv"
 	"github.com/gemfire/geode-go-client/query"
 	"errors"
+	"context"
+	"time"
 )
 
 //go:generate counterfeiter net.Conn
@@ -181,6 +183,74 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Context("Context", func() {
+		It("applies the context deadline to the connection", func() {
+			fakeConn.ReadStub = func(b []byte) (int, error) {
+				response := &v1.Message{
+					MessageType: &v1.Message_PutResponse{
+						PutResponse: &v1.PutResponse{},
+					},
+				}
+				return writeFakeMessage(response, b)
+			}
+
+			deadline := time.Now().Add(time.Minute)
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+
+			Expect(connection.PutContext(ctx, "foo", "A", "B")).To(Succeed())
+			Expect(fakeConn.SetDeadlineCallCount()).To(Equal(1))
+			Expect(fakeConn.SetDeadlineArgsForCall(0)).To(Equal(deadline))
+
+			// The deadline is cleared again for an operation without one
+			Expect(connection.Put("foo", "A", "B")).To(Succeed())
+			Expect(fakeConn.SetDeadlineCallCount()).To(Equal(2))
+			Expect(fakeConn.SetDeadlineArgsForCall(1).IsZero()).To(BeTrue())
+		})
+
+		It("interrupts and discards the connection when the context is cancelled", func() {
+			interrupted := make(chan struct{})
+			fakeConn.SetDeadlineStub = func(t time.Time) error {
+				if !t.IsZero() && t.Before(time.Now()) {
+					close(interrupted)
+				}
+				return nil
+			}
+			fakeConn.ReadStub = func(b []byte) (int, error) {
+				<-interrupted
+				return 0, errors.New("i/o timeout")
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+
+			_, err := connection.GetContext(ctx, "foo", "A", nil)
+			Expect(err).To(Equal(context.Canceled))
+			Expect(fakeConn.CloseCallCount()).To(Equal(1))
+		})
+
+		It("does not start an operation with a context which is already done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := connection.SizeContext(ctx, "foo")
+			Expect(err).To(Equal(context.Canceled))
+			Expect(fakeConn.WriteCallCount()).To(Equal(0))
+		})
+
+		It("stops waiting for a pooled connection when the context expires", func() {
+			pool.SetMaxConnections(1)
+			_, err := pool.GetConnection()
+			Expect(err).To(BeNil())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			_, err = connection.GetContext(ctx, "foo", "A", nil)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+	})
+
 	Context("Put", func() {
 		It("does not return an error", func() {
 			fakeConn.ReadStub = func(b []byte) (int, error) {
@@ -226,6 +296,34 @@ var _ = Describe("Client", func() {
 			json := struct{ A int }{1}
 			Expect(connection.Put("foo", "A", json)).To(BeNil())
 		})
+
+		It("does not reuse a request changed by an interceptor", func() {
+			putResponse := &v1.Message{
+				MessageType: &v1.Message_PutResponse{
+					PutResponse: &v1.PutResponse{},
+				},
+			}
+			respondWith(fakeConn, &v1.Message{
+				MessageType: &v1.Message_GetSizeResponse{
+					GetSizeResponse: &v1.GetSizeResponse{},
+				},
+			}, putResponse)
+
+			intercepted := connection.WithServerGroup("")
+			intercepted.AddInterceptor(func(ctx context.Context, op *connector.Operation, next connector.Invoker) (*v1.Message, error) {
+				op.Request.MessageType = &v1.Message_GetSizeRequest{
+					GetSizeRequest: &v1.GetSizeRequest{RegionName: "x"},
+				}
+				return next(ctx, op)
+			})
+
+			Expect(intercepted.Put("foo", "A", "B")).To(Succeed())
+			Expect(connection.Put("foo", "C", "D")).To(Succeed())
+
+			put := writtenMessage(fakeConn, 1).GetPutRequest()
+			Expect(put.GetRegionName()).To(Equal("foo"))
+			Expect(put.GetEntry().GetKey().GetStringResult()).To(Equal("C"))
+		})
 	})
 
 	Context("PutIfAbsent", func() {
@@ -788,11 +886,3 @@ var _ = Describe("Client", func() {
 		})
 	})
 })
-
-func writeFakeMessage(m proto.Message, b []byte) (int, error) {
-	p := proto.NewBuffer(nil)
-	p.EncodeMessage(m)
-	n := copy(b, p.Bytes())
-
-	return n, nil
-}
@@ -0,0 +1,154 @@
+package connector
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A RetryPolicy decides whether an operation which failed with a RetryableError should be
+// attempted again, and how long to wait before doing so.
+type RetryPolicy interface {
+	// ShouldRetry is called after a failed attempt. attempt is the number of attempts made so far,
+	// starting at 1. It returns how long to wait before the next attempt, and false if the
+	// operation should not be retried at all.
+	ShouldRetry(attempt int, err error) (time.Duration, bool)
+}
+
+// NoRetries is a RetryPolicy which never retries an operation.
+var NoRetries RetryPolicy = &ExponentialBackoff{MaxAttempts: 1}
+
+// DefaultRetryPolicy is the RetryPolicy used by a connector unless another is set.
+var DefaultRetryPolicy RetryPolicy = &ExponentialBackoff{
+	MaxAttempts:    5,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     1 * time.Second,
+	Jitter:         0.2,
+}
+
+// ExponentialBackoff is a RetryPolicy which allows up to MaxAttempts attempts of an operation.
+// The wait before the second attempt is InitialBackoff, doubling for each subsequent attempt up
+// to MaxBackoff. Each wait is randomly adjusted by up to the Jitter fraction in either direction,
+// so that clients failing at the same time do not all retry at the same time. If a Budget is set,
+// retries are also limited by the budget.
+type ExponentialBackoff struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64
+	Budget         *RetryBudget
+}
+
+var _ RetryPolicy = (*ExponentialBackoff)(nil)
+
+func (this *ExponentialBackoff) ShouldRetry(attempt int, err error) (time.Duration, bool) {
+	if attempt >= this.MaxAttempts {
+		return 0, false
+	}
+
+	if this.Budget != nil && !this.Budget.withdraw() {
+		return 0, false
+	}
+
+	backoff := this.InitialBackoff
+	for i := 1; i < attempt && (this.MaxBackoff <= 0 || backoff < this.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if this.MaxBackoff > 0 && backoff > this.MaxBackoff {
+		backoff = this.MaxBackoff
+	}
+
+	if this.Jitter > 0 {
+		backoff += time.Duration(float64(backoff) * this.Jitter * (2*rand.Float64() - 1))
+	}
+
+	return backoff, true
+}
+
+// A RetryBudget limits the rate of retries across all operations which share it, so that a
+// struggling cluster is not overwhelmed by retries. It allows a burst of retries, after which
+// retries are permitted at the given rate per second.
+type RetryBudget struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewRetryBudget(retriesPerSecond float64, burst int) *RetryBudget {
+	return &RetryBudget{
+		rate:   retriesPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// withdraw takes a retry from the budget, returning false if none are available.
+func (this *RetryBudget) withdraw() bool {
+	this.Lock()
+	defer this.Unlock()
+
+	now := time.Now()
+	this.tokens += now.Sub(this.last).Seconds() * this.rate
+	if this.tokens > this.burst {
+		this.tokens = this.burst
+	}
+	this.last = now
+
+	if this.tokens < 1 {
+		return false
+	}
+
+	this.tokens -= 1
+
+	return true
+}
+
+// An Attempt records the outcome of a single attempt of an operation.
+type Attempt struct {
+	Server string
+	Err    error
+}
+
+// A RetryError is returned when an operation fails after it has been attempted more than once,
+// or when it is abandoned while waiting to be attempted again. It records every attempt made and
+// the server it was sent to.
+type RetryError struct {
+	Attempts []Attempt
+
+	// Err is the reason the operation was abandoned before its next attempt, such as the context
+	// ending, or nil if every permitted attempt was made.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	attempts := make([]string, len(e.Attempts))
+	for i, a := range e.Attempts {
+		attempts[i] = fmt.Sprintf("attempt %d (%s): %s", i+1, a.Server, a.Err.Error())
+	}
+
+	noun := "attempts"
+	if len(e.Attempts) == 1 {
+		noun = "attempt"
+	}
+
+	if e.Err != nil {
+		return fmt.Sprintf("operation abandoned after %d %s: %s: %s", len(e.Attempts), noun, e.Err.Error(), strings.Join(attempts, "; "))
+	}
+
+	return fmt.Sprintf("operation failed after %d %s: %s", len(e.Attempts), noun, strings.Join(attempts, "; "))
+}
+
+// Unwrap returns the reason the operation was abandoned, if any, and otherwise the error from the
+// final attempt.
+func (e *RetryError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+
+	return e.Attempts[len(e.Attempts)-1].Err
+}
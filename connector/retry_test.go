@@ -0,0 +1,255 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retries", func() {
+
+	var connection *connector.Protobuf
+	var pool *connector.Pool
+	var brokenConns []*connectorfakes.FakeConn
+
+	BeforeEach(func() {
+		pool = connector.NewPool()
+		connection = connector.NewConnector(pool)
+
+		brokenConns = nil
+		for i := 0; i < 5; i++ {
+			c := new(connectorfakes.FakeConn)
+			c.WriteStub = func(b []byte) (int, error) {
+				return -1, &net.OpError{
+					Op:  "write",
+					Err: errors.New("fake retryable write error"),
+				}
+			}
+			pool.AddConnection(c, true)
+			brokenConns = append(brokenConns, c)
+		}
+	})
+
+	writeCount := func() int {
+		count := 0
+		for _, c := range brokenConns {
+			count += c.WriteCallCount()
+		}
+		return count
+	}
+
+	It("stops after the maximum number of attempts", func() {
+		connection.SetRetryPolicy(&connector.ExponentialBackoff{MaxAttempts: 3})
+
+		_, err := connection.Get("foo", "a", nil)
+		Expect(writeCount()).To(Equal(3))
+
+		var retryErr *connector.RetryError
+		Expect(errors.As(err, &retryErr)).To(BeTrue())
+		Expect(retryErr.Attempts).To(HaveLen(3))
+		Expect(err).To(MatchError(HavePrefix("operation failed after 3 attempts: attempt 1 (")))
+
+		var retryable *connector.RetryableError
+		Expect(errors.As(err, &retryable)).To(BeTrue())
+	})
+
+	It("does not wrap the error when only one attempt is made", func() {
+		connection.SetRetryPolicy(connector.NoRetries)
+
+		_, err := connection.Get("foo", "a", nil)
+		Expect(writeCount()).To(Equal(1))
+		Expect(err).To(BeAssignableToTypeOf(&connector.RetryableError{}))
+	})
+
+	It("limits retries with a budget", func() {
+		connection.SetRetryPolicy(&connector.ExponentialBackoff{
+			MaxAttempts: 5,
+			Budget:      connector.NewRetryBudget(0, 1),
+		})
+
+		_, err := connection.Get("foo", "a", nil)
+		Expect(writeCount()).To(Equal(2))
+		Expect(err).To(MatchError(HavePrefix("operation failed after 2 attempts")))
+	})
+
+	It("stops waiting to retry when the context is cancelled", func() {
+		connection.SetRetryPolicy(&connector.ExponentialBackoff{
+			MaxAttempts:    5,
+			InitialBackoff: time.Minute,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := connection.GetContext(ctx, "foo", "a", nil)
+		Expect(writeCount()).To(Equal(1))
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+
+		var retryErr *connector.RetryError
+		Expect(errors.As(err, &retryErr)).To(BeTrue())
+		Expect(retryErr.Attempts).To(HaveLen(1))
+		Expect(retryErr.Err).To(Equal(context.DeadlineExceeded))
+		Expect(err).To(MatchError(HavePrefix("operation abandoned after 1 attempt: context deadline exceeded: attempt 1 (")))
+		Expect(err.Error()).ToNot(ContainSubstring("()"))
+	})
+
+	Context("ExponentialBackoff", func() {
+		It("doubles the backoff up to the maximum", func() {
+			policy := &connector.ExponentialBackoff{
+				MaxAttempts:    10,
+				InitialBackoff: 10 * time.Millisecond,
+				MaxBackoff:     50 * time.Millisecond,
+			}
+
+			var backoffs []time.Duration
+			for attempt := 1; attempt < 5; attempt++ {
+				backoff, retry := policy.ShouldRetry(attempt, nil)
+				Expect(retry).To(BeTrue())
+				backoffs = append(backoffs, backoff)
+			}
+
+			Expect(backoffs).To(Equal([]time.Duration{
+				10 * time.Millisecond,
+				20 * time.Millisecond,
+				40 * time.Millisecond,
+				50 * time.Millisecond,
+			}))
+		})
+
+		It("keeps jitter within bounds", func() {
+			policy := &connector.ExponentialBackoff{
+				MaxAttempts:    2,
+				InitialBackoff: 100 * time.Millisecond,
+				Jitter:         0.2,
+			}
+
+			for i := 0; i < 100; i++ {
+				backoff, _ := policy.ShouldRetry(1, nil)
+				Expect(backoff).To(BeNumerically(">=", 80*time.Millisecond))
+				Expect(backoff).To(BeNumerically("<=", 120*time.Millisecond))
+			}
+		})
+	})
+
+	Context("after the request was sent", func() {
+
+		var healthyFakeConn, eofFakeConn *connectorfakes.FakeConn
+
+		BeforeEach(func() {
+			pool = connector.NewPool()
+			connection = connector.NewConnector(pool)
+
+			healthyFakeConn = new(connectorfakes.FakeConn)
+			healthyFakeConn.ReadStub = func(b []byte) (int, error) {
+				return writeFakeMessage(&v1.Message{
+					MessageType: &v1.Message_PutIfAbsentResponse{
+						PutIfAbsentResponse: &v1.PutIfAbsentResponse{},
+					},
+				}, b)
+			}
+			pool.AddConnection(healthyFakeConn, true)
+
+			// Connections are pulled off in the reverse order of their addition, so this one is used first
+			eofFakeConn = new(connectorfakes.FakeConn)
+			eofFakeConn.ReadReturns(0, io.EOF)
+			pool.AddConnection(eofFakeConn, true)
+		})
+
+		It("returns an OutcomeUnknownError for operations which are not idempotent", func() {
+			err := connection.PutIfAbsent("foo", "a", 1)
+
+			var outcomeUnknown *connector.OutcomeUnknownError
+			Expect(errors.As(err, &outcomeUnknown)).To(BeTrue())
+			Expect(errors.Is(err, io.EOF)).To(BeTrue())
+			Expect(err).To(MatchError("outcome of operation is unknown: EOF"))
+			Expect(healthyFakeConn.WriteCallCount()).To(Equal(0))
+		})
+
+		It("returns an OutcomeUnknownError when the connection is reset", func() {
+			reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+			eofFakeConn.ReadReturns(0, reset)
+
+			err := connection.PutIfAbsent("foo", "a", 1)
+
+			var outcomeUnknown *connector.OutcomeUnknownError
+			Expect(errors.As(err, &outcomeUnknown)).To(BeTrue())
+			Expect(errors.Is(err, syscall.ECONNRESET)).To(BeTrue())
+			Expect(healthyFakeConn.WriteCallCount()).To(Equal(0))
+		})
+
+		It("returns an OutcomeUnknownError when the response is cut short", func() {
+			// A length prefix promising more data than is sent
+			reads := 0
+			eofFakeConn.ReadStub = func(b []byte) (int, error) {
+				reads += 1
+				if reads == 1 {
+					return copy(b, []byte{10, 0, 0}), nil
+				}
+				return 0, io.EOF
+			}
+
+			err := connection.PutIfAbsent("foo", "a", 1)
+
+			var outcomeUnknown *connector.OutcomeUnknownError
+			Expect(errors.As(err, &outcomeUnknown)).To(BeTrue())
+			Expect(errors.Is(err, io.ErrUnexpectedEOF)).To(BeTrue())
+		})
+
+		It("returns an OutcomeUnknownError when the context ends while waiting for the response", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			eofFakeConn.ReadStub = func(b []byte) (int, error) {
+				<-ctx.Done()
+				return 0, &net.OpError{Op: "read", Net: "tcp", Err: errors.New("i/o timeout")}
+			}
+
+			err := connection.PutIfAbsentContext(ctx, "foo", "a", 1)
+
+			var outcomeUnknown *connector.OutcomeUnknownError
+			Expect(errors.As(err, &outcomeUnknown)).To(BeTrue())
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		})
+
+		It("does not return an OutcomeUnknownError when the request could not be sent", func() {
+			eofFakeConn.WriteReturns(0, errors.New("broken pipe"))
+
+			err := connection.PutIfAbsent("foo", "a", 1)
+
+			var outcomeUnknown *connector.OutcomeUnknownError
+			Expect(errors.As(err, &outcomeUnknown)).To(BeFalse())
+			Expect(err).To(MatchError("broken pipe"))
+		})
+
+		It("replays operations which are not idempotent when asked to", func() {
+			connection.SetReplayNonIdempotent(true)
+
+			err := connection.PutIfAbsent("foo", "a", 1)
+			Expect(err).To(BeNil())
+			Expect(healthyFakeConn.WriteCallCount()).To(Equal(1))
+		})
+
+		It("retries idempotent operations", func() {
+			healthyFakeConn.ReadStub = func(b []byte) (int, error) {
+				return writeFakeMessage(&v1.Message{
+					MessageType: &v1.Message_PutResponse{
+						PutResponse: &v1.PutResponse{},
+					},
+				}, b)
+			}
+
+			err := connection.Put("foo", "a", 1)
+			Expect(err).To(BeNil())
+			Expect(healthyFakeConn.WriteCallCount()).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package connector
+
+import (
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+)
+
+// A CustomEncodedValue is a value which has already been serialized in the format selected with
+// SetValueSerializer. It is sent to servers as is, and custom objects received from servers are
+// returned as CustomEncodedValues if no ValueSerializer has been set.
+type CustomEncodedValue []byte
+
+// A ValueSerializer converts values to and from a custom binary format, such as the format used
+// by services written in other languages. Servers must have a ValueSerializer registered for the
+// same format, which they use to convert custom objects to and from the objects they store.
+//
+// Values of the types the protocol supports natively, such as strings and numbers, are always
+// sent as those types. Other values are passed to Serialize, and are encoded as JSON if it
+// declines them.
+type ValueSerializer interface {
+	// Format returns the ID sent to servers during the handshake to select their serializer.
+	Format() string
+
+	// Serialize encodes a value. If ok is false the value is not handled by this serializer.
+	Serialize(value interface{}) (data []byte, ok bool, err error)
+
+	// Deserialize decodes a custom object received from a server. ref is the value passed by the
+	// caller to receive the result, which may be nil.
+	Deserialize(data []byte, ref interface{}) (interface{}, error)
+}
+
+// SetValueSerializer sets the serializer used for custom objects. Its format is selected on every
+// connection during the handshake, so it must be set before the pool is used.
+func (this *Pool) SetValueSerializer(serializer ValueSerializer) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.serializer = serializer
+}
+
+func (this *Pool) valueSerializer() ValueSerializer {
+	this.RLock()
+	defer this.RUnlock()
+
+	return this.serializer
+}
+
+// valueFormat returns the format to select during the handshake, or an empty string if servers
+// should use their default.
+func (this *Pool) valueFormat() string {
+	if serializer := this.valueSerializer(); serializer != nil {
+		return serializer.Format()
+	}
+
+	return ""
+}
+
+// serializeValue encodes a value which the protocol does not support natively as a custom object,
+// returning false if the serializer declines it.
+func serializeValue(ev *v1.EncodedValue, val interface{}, serializer ValueSerializer) (bool, error) {
+	if serializer == nil {
+		return false, nil
+	}
+
+	data, ok, err := serializer.Serialize(val)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	ev.Value = &v1.EncodedValue_CustomObjectResult{CustomObjectResult: data}
+
+	return true, nil
+}
+
+// deserializeValue decodes a custom object received from a server.
+func deserializeValue(data []byte, ref interface{}, serializer ValueSerializer) (interface{}, error) {
+	if serializer == nil {
+		return CustomEncodedValue(data), nil
+	}
+
+	return serializer.Deserialize(data, ref)
+}
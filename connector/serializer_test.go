@@ -0,0 +1,125 @@
+package connector_test
+
+import (
+	"fmt"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type point struct {
+	X, Y int32
+}
+
+// pointSerializer encodes points as text, and declines every other type
+type pointSerializer struct{}
+
+func (pointSerializer) Format() string {
+	return "points"
+}
+
+func (pointSerializer) Serialize(value interface{}) ([]byte, bool, error) {
+	p, ok := value.(point)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), true, nil
+}
+
+func (pointSerializer) Deserialize(data []byte, ref interface{}) (interface{}, error) {
+	p := point{}
+	if _, err := fmt.Sscanf(string(data), "%d,%d", &p.X, &p.Y); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+var _ = Describe("Value serialization", func() {
+
+	var connection *connector.Protobuf
+	var fakeConn *connectorfakes.FakeConn
+	var pool *connector.Pool
+
+	customResult := func(data string) *v1.Message {
+		return &v1.Message{
+			MessageType: &v1.Message_GetResponse{
+				GetResponse: &v1.GetResponse{
+					Result: &v1.EncodedValue{
+						Value: &v1.EncodedValue_CustomObjectResult{CustomObjectResult: []byte(data)},
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		connection, pool, fakeConn = newFakeConnector()
+	})
+
+	Context("with a serializer", func() {
+		BeforeEach(func() {
+			pool.SetValueSerializer(pointSerializer{})
+			respondWith(fakeConn, &v1.Message{
+				MessageType: &v1.Message_HandshakeResponse{
+					HandshakeResponse: &v1.HandshakeResponse{Authenticated: true},
+				},
+			}, &v1.Message{
+				MessageType: &v1.Message_PutResponse{
+					PutResponse: &v1.PutResponse{},
+				},
+			}, customResult("3,4"))
+		})
+
+		It("selects the serializer's format during the handshake", func() {
+			Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+			handshake := writtenMessage(fakeConn, 0).GetHandshakeRequest()
+			Expect(handshake.GetValueFormat()).To(Equal("points"))
+			Expect(handshake.GetCredentials()).To(BeEmpty())
+		})
+
+		It("encodes values the serializer handles as custom objects", func() {
+			Expect(connection.Put("foo", "a", point{1, 2})).To(Succeed())
+
+			value := writtenMessage(fakeConn, 1).GetPutRequest().GetEntry().GetValue()
+			Expect(value.GetCustomObjectResult()).To(Equal([]byte("1,2")))
+		})
+
+		It("encodes other values as usual", func() {
+			Expect(connection.Put("foo", "a", TestStruct{Value: 1})).To(Succeed())
+
+			value := writtenMessage(fakeConn, 1).GetPutRequest().GetEntry().GetValue()
+			Expect(value.GetJsonObjectResult()).To(Equal(`{"Value":1,"Message":""}`))
+		})
+
+		It("decodes custom objects", func() {
+			Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+			v, err := connection.Get("foo", "a", nil)
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal(point{3, 4}))
+		})
+	})
+
+	Context("without a serializer", func() {
+		It("returns custom objects in their encoded form", func() {
+			respondWith(fakeConn, customResult("3,4"))
+
+			v, err := connection.Get("foo", "a", nil)
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal(connector.CustomEncodedValue("3,4")))
+			Expect(fakeConn.WriteCallCount()).To(Equal(1))
+		})
+
+		It("sends values which are already encoded as custom objects", func() {
+			v, err := connector.EncodeValue(connector.CustomEncodedValue("1,2"))
+			Expect(err).To(BeNil())
+			Expect(v.GetCustomObjectResult()).To(Equal([]byte("1,2")))
+		})
+	})
+})
@@ -1,27 +1,48 @@
 package connector
 
 import (
-	"net"
+	"context"
+	"errors"
 	"fmt"
 )
 
 type serverConnectionProvider struct {
-	host string
-	port int
+	host      string
+	port      int
+	health    *serverHealth
+	transport *transport
+	key       string
 }
 
 var _ ConnectionProvider = (*serverConnectionProvider)(nil)
 
-func (this *serverConnectionProvider) GetGeodeConnection() *GeodeConnection {
-	c, err := net.Dial("tcp", fmt.Sprintf("%s:%d", this.host, this.port))
+// address returns the server's address as host:port.
+func (this *serverConnectionProvider) address() string {
+	if this.key == "" {
+		this.key = serverKey(this.host, this.port)
+	}
+
+	return this.key
+}
+
+func (this *serverConnectionProvider) GetGeodeConnection(ctx context.Context) (*GeodeConnection, error) {
+	if this.health.isQuarantined(this.host, this.port) {
+		return nil, errors.New(fmt.Sprintf("server %s is unavailable after a recent failure", serverKey(this.host, this.port)))
+	}
+
+	c, err := this.transport.dialServer(ctx, this.host, this.port)
 	if err != nil {
-		return nil
+		this.health.failed(this.host, this.port)
+		return nil, err
 	}
 
 	return &GeodeConnection{
 		rawConn:            c,
+		host:               this.host,
+		port:               this.port,
+		address:            connectionAddress(c, this.host, this.port),
 		inUse:              false,
 		handshakeDone:      false,
 		authenticationDone: false,
-	}
+	}, nil
 }
@@ -0,0 +1,124 @@
+package connector
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+)
+
+const defaultInitialFailureBackoff = 1 * time.Second
+const defaultMaxFailureBackoff = 30 * time.Second
+
+// serverHealth tracks servers which could not be connected to, so that they can be avoided for a
+// while. Each consecutive failure doubles the time a server is quarantined, up to a maximum. Once
+// the quarantine has expired the server may be tried again and a successful connection clears
+// its history.
+type serverHealth struct {
+	sync.Mutex
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	servers        map[string]*serverState
+}
+
+type serverState struct {
+	host     string
+	port     int
+	failures uint
+	retryAt  time.Time
+}
+
+func newServerHealth() *serverHealth {
+	return &serverHealth{
+		initialBackoff: defaultInitialFailureBackoff,
+		maxBackoff:     defaultMaxFailureBackoff,
+		servers:        make(map[string]*serverState),
+	}
+}
+
+func serverKey(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func (this *serverHealth) setBackoff(initial, max time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.initialBackoff = initial
+	this.maxBackoff = max
+}
+
+// failed records a failure to connect to a server and quarantines it.
+func (this *serverHealth) failed(host string, port int) {
+	if host == "" {
+		return
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	key := serverKey(host, port)
+	state, ok := this.servers[key]
+	if !ok {
+		state = &serverState{
+			host: host,
+			port: port,
+		}
+		this.servers[key] = state
+	}
+
+	backoff := this.initialBackoff
+	for i := uint(0); i < state.failures && backoff < this.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > this.maxBackoff {
+		backoff = this.maxBackoff
+	}
+
+	state.failures += 1
+	state.retryAt = time.Now().Add(backoff)
+}
+
+// succeeded clears any failures previously recorded for a server.
+func (this *serverHealth) succeeded(host string, port int) {
+	if host == "" {
+		return
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	delete(this.servers, serverKey(host, port))
+}
+
+// isQuarantined returns true if the server has failed recently and should not yet be tried again.
+func (this *serverHealth) isQuarantined(host string, port int) bool {
+	this.Lock()
+	defer this.Unlock()
+
+	state, ok := this.servers[serverKey(host, port)]
+
+	return ok && time.Now().Before(state.retryAt)
+}
+
+// quarantined returns all currently quarantined servers, suitable for use as the excluded
+// servers of a GetServerRequest.
+func (this *serverHealth) quarantined() []*v1.Server {
+	this.Lock()
+	defer this.Unlock()
+
+	now := time.Now()
+	servers := make([]*v1.Server, 0)
+	for _, state := range this.servers {
+		if now.Before(state.retryAt) {
+			servers = append(servers, &v1.Server{
+				Hostname: state.host,
+				Port:     int32(state.port),
+			})
+		}
+	}
+
+	return servers
+}
@@ -0,0 +1,74 @@
+package connector_test
+
+import (
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server health", func() {
+
+	var pool *connector.Pool
+
+	BeforeEach(func() {
+		pool = connector.NewPool()
+		pool.SetFailureBackoff(200*time.Millisecond, time.Second)
+	})
+
+	It("quarantines a failed server and tries it again later", func() {
+		listener := startFakeGeodeMember(alwaysLocate(nil))
+		address := listener.Addr().String()
+		host, port := hostAndPort(listener)
+		listener.Close()
+
+		pool.AddServer(host, port)
+
+		_, err := pool.GetConnection()
+		Expect(err).ToNot(BeNil())
+
+		listener = startFakeGeodeMemberAt(address, alwaysLocate(nil))
+		defer listener.Close()
+
+		_, err = pool.GetConnection()
+		Expect(err).To(MatchError(ContainSubstring("unavailable after a recent failure")))
+
+		Eventually(func() error {
+			_, err := pool.GetConnection()
+			return err
+		}).Should(BeNil())
+	})
+
+	It("excludes quarantined servers when asking a locator for a server", func() {
+		deadListener := startFakeGeodeMember(alwaysLocate(nil))
+		deadServer := serverFromListener(deadListener)
+		deadListener.Close()
+
+		liveListener := startFakeGeodeMember(alwaysLocate(nil))
+		defer liveListener.Close()
+
+		excludedServers := make(chan []*v1.Server, 10)
+		locatorListener := startFakeGeodeMember(func(request *v1.GetServerRequest) *v1.Server {
+			excludedServers <- request.GetExcludedServers()
+			if len(request.GetExcludedServers()) == 0 {
+				return deadServer
+			}
+			return serverFromListener(liveListener)
+		})
+		defer locatorListener.Close()
+
+		host, port := hostAndPort(locatorListener)
+		pool.AddLocator(host, port)
+
+		gConn, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+		Expect(gConn.GetRawConnection().RemoteAddr().String()).To(Equal(liveListener.Addr().String()))
+		Expect(<-excludedServers).To(BeEmpty())
+		excluded := <-excludedServers
+		Expect(excluded).To(HaveLen(1))
+		Expect(excluded[0].Hostname).To(Equal(deadServer.Hostname))
+		Expect(excluded[0].Port).To(Equal(deadServer.Port))
+	})
+})
@@ -0,0 +1,98 @@
+package connector
+
+import (
+	"context"
+)
+
+// Attributes set on the spans started by a pool's Tracer
+const (
+	AttributeRegion       = "geode.region"
+	AttributeServer       = "geode.server.address"
+	AttributeAttempt      = "geode.attempt"
+	AttributeRequestSize  = "geode.request.size"
+	AttributeResponseSize = "geode.response.size"
+	AttributeErrorCode    = "geode.error.code"
+)
+
+// A Tracer starts the spans which record operations in distributed traces. It is intended to be
+// implemented with a tracing library such as OpenTelemetry.
+//
+// Each operation is recorded as a span named after it, such as "Get", with the attributes above.
+// Acquiring a connection from the pool, the handshake and authentication are recorded as child
+// spans named "AcquireConnection", "Handshake" and "Authenticate".
+type Tracer interface {
+	// StartSpan starts a span which is a child of the span in ctx, if any, and returns a context
+	// holding the new span.
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span records a unit of work started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute, whose value is either a string or an int.
+	SetAttribute(key string, value interface{})
+
+	// RecordError marks the span as failed.
+	RecordError(err error)
+
+	End()
+}
+
+// SetTracer sets the Tracer used to record operations performed with the pool. By default
+// operations are not traced.
+func (this *Pool) SetTracer(tracer Tracer) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.tracer = tracer
+}
+
+// startSpan starts a span if the pool has a Tracer, and otherwise returns a nil Span.
+func (this *Pool) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	this.RLock()
+	tracer := this.tracer
+	this.RUnlock()
+
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	return tracer.StartSpan(ctx, name)
+}
+
+// finishSpan records the outcome of the work recorded by a span, which may be nil, and ends it.
+func finishSpan(span Span, err error) {
+	if span == nil {
+		return
+	}
+
+	if err != nil {
+		span.SetAttribute(AttributeErrorCode, ErrorCodeLabel(errorCode(err)))
+		span.RecordError(err)
+	}
+
+	span.End()
+}
+
+type operationSpanKey struct{}
+
+// startOperationSpan starts the span recording an operation. The span is also kept in the returned
+// context so that each attempt of the operation can add to it.
+func (this *Pool) startOperationSpan(ctx context.Context, name, region string) (context.Context, Span) {
+	ctx, span := this.startSpan(ctx, name)
+	if span == nil {
+		return ctx, nil
+	}
+
+	if region != "" {
+		span.SetAttribute(AttributeRegion, region)
+	}
+
+	return context.WithValue(ctx, operationSpanKey{}, span), span
+}
+
+// operationSpan returns the span recording the operation being performed with ctx, or nil if the
+// operation is not traced.
+func operationSpan(ctx context.Context) Span {
+	span, _ := ctx.Value(operationSpanKey{}).(Span)
+	return span
+}
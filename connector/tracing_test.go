@@ -0,0 +1,154 @@
+package connector_test
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	"github.com/gemfire/geode-go-client/protobuf"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type recordedSpan struct {
+	name       string
+	parent     *recordedSpan
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (this *recordedSpan) SetAttribute(key string, value interface{}) {
+	this.attributes[key] = value
+}
+
+func (this *recordedSpan) RecordError(err error) {
+	this.err = err
+}
+
+func (this *recordedSpan) End() {
+	this.ended = true
+}
+
+type recordedSpanKey struct{}
+
+// recordingTracer keeps every span it starts in memory
+type recordingTracer struct {
+	sync.Mutex
+	spans []*recordedSpan
+}
+
+func (this *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, connector.Span) {
+	this.Lock()
+	defer this.Unlock()
+
+	parent, _ := ctx.Value(recordedSpanKey{}).(*recordedSpan)
+	span := &recordedSpan{
+		name:       name,
+		parent:     parent,
+		attributes: make(map[string]interface{}),
+	}
+	this.spans = append(this.spans, span)
+
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+func (this *recordingTracer) span(name string) *recordedSpan {
+	for _, s := range this.spans {
+		if s.name == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+var _ = Describe("Tracing", func() {
+
+	var connection *connector.Protobuf
+	var pool *connector.Pool
+	var tracer *recordingTracer
+	var response *v1.Message
+
+	BeforeEach(func() {
+		response = &v1.Message{
+			MessageType: &v1.Message_PutResponse{
+				PutResponse: &v1.PutResponse{},
+			},
+		}
+
+		tracer = &recordingTracer{}
+		pool = connector.NewPool()
+		pool.SetTracer(tracer)
+		pool.AddCredentials("user", "secret")
+		pool.SetDialer(connector.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn := new(connectorfakes.FakeConn)
+			respondWith(conn, &org_apache_geode_internal_protocol_protobuf.VersionAcknowledgement{
+				ServerMajorVersion: 1,
+				ServerMinorVersion: 1,
+				VersionAccepted:    true,
+			}, &v1.Message{
+				MessageType: &v1.Message_HandshakeResponse{
+					HandshakeResponse: &v1.HandshakeResponse{Authenticated: true},
+				},
+			}, response)
+			return conn, nil
+		}))
+		pool.AddServer("server", 40404)
+		connection = connector.NewConnector(pool)
+	})
+
+	It("records a span for each operation", func() {
+		Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+		span := tracer.span("Put")
+		Expect(span).ToNot(BeNil())
+		Expect(span.parent).To(BeNil())
+		Expect(span.ended).To(BeTrue())
+		Expect(span.err).To(BeNil())
+		Expect(span.attributes).To(HaveKeyWithValue(connector.AttributeRegion, "foo"))
+		Expect(span.attributes).To(HaveKeyWithValue(connector.AttributeServer, "server:40404"))
+		Expect(span.attributes).To(HaveKeyWithValue(connector.AttributeAttempt, 1))
+		Expect(span.attributes).To(HaveKey(connector.AttributeRequestSize))
+		Expect(span.attributes).To(HaveKeyWithValue(connector.AttributeResponseSize, proto.Size(response)))
+	})
+
+	It("records acquiring and preparing the connection as child spans", func() {
+		Expect(connection.Put("foo", "a", 1)).To(Succeed())
+
+		put := tracer.span("Put")
+		acquire := tracer.span("AcquireConnection")
+		Expect(acquire.parent).To(Equal(put))
+		Expect(acquire.attributes).To(HaveKeyWithValue(connector.AttributeServer, "server:40404"))
+		Expect(tracer.span("Handshake").parent).To(Equal(acquire))
+		Expect(tracer.span("Authenticate").parent).To(Equal(acquire))
+
+		for _, s := range tracer.spans {
+			Expect(s.ended).To(BeTrue(), s.name)
+		}
+	})
+
+	It("records the error code of failed operations", func() {
+		response = &v1.Message{
+			MessageType: &v1.Message_ErrorResponse{
+				ErrorResponse: &v1.ErrorResponse{
+					Error: &v1.Error{
+						ErrorCode: v1.ErrorCode_AUTHORIZATION_FAILED,
+						Message:   "not authorized",
+					},
+				},
+			},
+		}
+
+		err := connection.Put("foo", "a", 1)
+		Expect(err).ToNot(BeNil())
+
+		span := tracer.span("Put")
+		Expect(span.err).To(Equal(err))
+		Expect(span.attributes).To(HaveKeyWithValue(connector.AttributeErrorCode, "AUTHORIZATION_FAILED"))
+	})
+})
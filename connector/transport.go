@@ -0,0 +1,110 @@
+package connector
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultDialTimeout = 30 * time.Second
+const defaultKeepAlive = 30 * time.Second
+
+// A Dialer establishes the network connections to servers and locators. *net.Dialer satisfies
+// this interface, as do the dialers of most proxy packages.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialerFunc allows an ordinary function to be used as a Dialer; for example one returning one
+// end of a net.Pipe in tests.
+type DialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (f DialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// A transport establishes the raw network connections to servers and locators on behalf of a
+// pool and its connection providers.
+type transport struct {
+	sync.RWMutex
+	dialer     Dialer
+	serverTLS  *tls.Config
+	locatorTLS *tls.Config
+}
+
+func newTransport() *transport {
+	return &transport{
+		dialer: &net.Dialer{
+			Timeout:   defaultDialTimeout,
+			KeepAlive: defaultKeepAlive,
+		},
+	}
+}
+
+func (this *transport) setDialer(dialer Dialer) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.dialer = dialer
+}
+
+func (this *transport) setServerTLS(config *tls.Config) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.serverTLS = config
+}
+
+func (this *transport) setLocatorTLS(config *tls.Config) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.locatorTLS = config
+}
+
+func (this *transport) dialServer(ctx context.Context, host string, port int) (net.Conn, error) {
+	this.RLock()
+	dialer, config := this.dialer, this.serverTLS
+	this.RUnlock()
+
+	return dial(ctx, dialer, host, port, config)
+}
+
+func (this *transport) dialLocator(ctx context.Context, host string, port int) (net.Conn, error) {
+	this.RLock()
+	dialer, config := this.dialer, this.locatorTLS
+	this.RUnlock()
+
+	return dial(ctx, dialer, host, port, config)
+}
+
+// dial connects to the given address, using TLS if a configuration is provided. When TLS is used
+// the TLS handshake is completed before returning, so that certificate problems are detected
+// when connecting rather than on first use. If the configuration does not name a server, the
+// host is used for SNI and to verify the server's certificate.
+func dial(ctx context.Context, dialer Dialer, host string, port int, config *tls.Config) (net.Conn, error) {
+	c, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return c, nil
+	}
+
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	tlsConn := tls.Client(c, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
@@ -0,0 +1,245 @@
+package connector_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/gemfire/geode-go-client/connector"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS", func() {
+
+	var pool *connector.Pool
+	var ca *testCertificateAuthority
+	var clientConfig *tls.Config
+
+	BeforeEach(func() {
+		pool = connector.NewPool()
+		ca = newTestCertificateAuthority()
+
+		clientConfig = &tls.Config{
+			RootCAs: ca.pool(),
+		}
+	})
+
+	startTLSMember := func(config *tls.Config) net.Listener {
+		rawListener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+
+		return serveFakeGeodeListener(tls.NewListener(rawListener, config), alwaysLocate(nil))
+	}
+
+	It("connects to a server using TLS", func() {
+		listener := startTLSMember(&tls.Config{
+			Certificates: []tls.Certificate{ca.issue("127.0.0.1")},
+		})
+		defer listener.Close()
+
+		pool.SetTLSConfig(clientConfig)
+		pool.AddServer(hostAndPort(listener))
+
+		gConn, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+		Expect(gConn.GetRawConnection()).To(BeAssignableToTypeOf(&tls.Conn{}))
+	})
+
+	It("does not connect to a server whose certificate cannot be verified", func() {
+		listener := startTLSMember(&tls.Config{
+			Certificates: []tls.Certificate{newTestCertificateAuthority().issue("127.0.0.1")},
+		})
+		defer listener.Close()
+
+		pool.SetTLSConfig(clientConfig)
+		pool.AddServer(hostAndPort(listener))
+
+		_, err := pool.GetConnection()
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("does not connect to a server whose certificate does not match its host name", func() {
+		listener := startTLSMember(&tls.Config{
+			Certificates: []tls.Certificate{ca.issue("10.0.0.1")},
+		})
+		defer listener.Close()
+
+		pool.SetTLSConfig(clientConfig)
+		pool.AddServer(hostAndPort(listener))
+
+		_, err := pool.GetConnection()
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("presents a client certificate for mutual TLS", func() {
+		listener := startTLSMember(&tls.Config{
+			Certificates: []tls.Certificate{ca.issue("127.0.0.1")},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    ca.pool(),
+		})
+		defer listener.Close()
+
+		clientConfig.Certificates = []tls.Certificate{ca.issue("client")}
+		pool.SetTLSConfig(clientConfig)
+		pool.AddServer(hostAndPort(listener))
+
+		_, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+	})
+
+	It("uses TLS to talk to locators", func() {
+		serverListener := startFakeGeodeMember(alwaysLocate(nil))
+		defer serverListener.Close()
+
+		rawListener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		locatorListener := serveFakeGeodeListener(tls.NewListener(rawListener, &tls.Config{
+			Certificates: []tls.Certificate{ca.issue("127.0.0.1")},
+		}), alwaysLocate(serverFromListener(serverListener)))
+		defer locatorListener.Close()
+
+		pool.SetLocatorTLSConfig(clientConfig)
+		pool.AddLocator(hostAndPort(locatorListener))
+
+		gConn, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+		Expect(gConn.GetRawConnection().RemoteAddr().String()).To(Equal(serverListener.Addr().String()))
+	})
+})
+
+var _ = Describe("Dialer", func() {
+
+	var pool *connector.Pool
+
+	BeforeEach(func() {
+		pool = connector.NewPool()
+	})
+
+	It("connects using the configured dialer", func() {
+		dialed := make(chan string, 1)
+		pool.SetDialer(connector.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed <- address
+			client, server := net.Pipe()
+			go serveFakeGeodeMember(server, alwaysLocate(nil))
+			return client, nil
+		}))
+		pool.AddServer("in-memory", 1)
+
+		_, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+		Expect(<-dialed).To(Equal("in-memory:1"))
+	})
+
+	It("can use a net.Dialer", func() {
+		listener := startFakeGeodeMember(alwaysLocate(nil))
+		defer listener.Close()
+
+		pool.SetDialer(&net.Dialer{Timeout: time.Second})
+		pool.AddServer(hostAndPort(listener))
+
+		_, err := pool.GetConnection()
+		Expect(err).To(BeNil())
+	})
+
+	It("returns the error from the dialer", func() {
+		dialErr := errors.New("proxy refused connection")
+		pool.SetDialer(connector.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, dialErr
+		}))
+		pool.AddServer("localhost", 40404)
+
+		_, err := pool.GetConnection()
+		Expect(errors.Is(err, dialErr)).To(BeTrue())
+		Expect(err).To(MatchError("no connections available: proxy refused connection"))
+	})
+
+	It("passes the context to the dialer", func() {
+		pool.SetDialer(connector.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}))
+		pool.AddServer("localhost", 40404)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := pool.GetConnectionContext(ctx, "")
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+	})
+})
+
+type testCertificateAuthority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCertificateAuthority() *testCertificateAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).To(BeNil())
+
+	certificate, err := x509.ParseCertificate(der)
+	Expect(err).To(BeNil())
+
+	return &testCertificateAuthority{
+		certificate: certificate,
+		key:         key,
+	}
+}
+
+func (this *testCertificateAuthority) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(this.certificate)
+
+	return pool
+}
+
+// issue creates a certificate for the given name, which is either an IP address or a DNS name.
+func (this *testCertificateAuthority) issue(name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{name}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, this.certificate, &key.PublicKey, this.key)
+	Expect(err).To(BeNil())
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
@@ -0,0 +1,89 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gemfire/geode-go-client/protobuf"
+)
+
+// A ProtocolVersion is a version of the Geode client protocol.
+type ProtocolVersion struct {
+	Major uint32
+	Minor uint32
+}
+
+func (v ProtocolVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// The version of the protocol requested by this client
+var clientVersion = ProtocolVersion{
+	Major: MAJOR_VERSION,
+	Minor: MINOR_VERSION,
+}
+
+// ServerInfo describes a server and the version of the protocol used to talk to it.
+type ServerInfo struct {
+	Address string
+	Version ProtocolVersion
+}
+
+// An UnsupportedOperationError is returned when a server rejects an operation as unsupported by
+// the version of the protocol it speaks. Err holds the server's error.
+type UnsupportedOperationError struct {
+	Operation string
+	Version   ProtocolVersion
+	Err       error
+}
+
+func (e *UnsupportedOperationError) Error() string {
+	return fmt.Sprintf("%s is unsupported by server (protocol version %s)", e.Operation, e.Version)
+}
+
+func (e *UnsupportedOperationError) Unwrap() error {
+	return e.Err
+}
+
+// negotiateVersion returns the version of the protocol to use with a server which accepted the
+// client's version. The client and server must agree on the major version, and the earlier of the
+// two minor versions is used. A server which does not report its own version, sending 0.0, is
+// assumed to speak the client's. Otherwise a minor version of 0 is rejected: the protocol defines
+// it as INVALID_MINOR_VERSION, as 1.1 was the first release.
+func negotiateVersion(ack *org_apache_geode_internal_protocol_protobuf.VersionAcknowledgement) (ProtocolVersion, error) {
+	server := ProtocolVersion{
+		Major: uint32(ack.GetServerMajorVersion()),
+		Minor: uint32(ack.GetServerMinorVersion()),
+	}
+
+	if server == (ProtocolVersion{}) {
+		return clientVersion, nil
+	}
+
+	if server.Major != clientVersion.Major || server.Minor == uint32(org_apache_geode_internal_protocol_protobuf.MinorVersions_INVALID_MINOR_VERSION) {
+		return ProtocolVersion{}, errors.New(fmt.Sprintf("server protocol version %s is incompatible with client version %s", server, clientVersion))
+	}
+
+	if server.Minor > clientVersion.Minor {
+		server.Minor = clientVersion.Minor
+	}
+
+	return server, nil
+}
+
+// ServerInfo returns the address of a server the connector uses, and the protocol version
+// negotiated with it. Servers are chosen in the same way as for any other operation, so
+// successive calls may describe different servers.
+func (this *Protobuf) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	gConn, err := this.getConnection(ctx)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	defer this.pool.ReturnConnection(gConn)
+
+	return ServerInfo{
+		Address: gConn.serverAddress(),
+		Version: gConn.serverVersion,
+	}, nil
+}
@@ -0,0 +1,112 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/gemfire/geode-go-client/connector"
+	"github.com/gemfire/geode-go-client/connector/connectorfakes"
+	"github.com/gemfire/geode-go-client/protobuf"
+	v1 "github.com/gemfire/geode-go-client/protobuf/v1"
+	"github.com/golang/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Protocol version", func() {
+
+	var connection *connector.Protobuf
+	var pool *connector.Pool
+	var ack *org_apache_geode_internal_protocol_protobuf.VersionAcknowledgement
+	var response *v1.Message
+
+	BeforeEach(func() {
+		ack = &org_apache_geode_internal_protocol_protobuf.VersionAcknowledgement{
+			ServerMajorVersion: 1,
+			ServerMinorVersion: 1,
+			VersionAccepted:    true,
+		}
+		response = &v1.Message{
+			MessageType: &v1.Message_PutResponse{
+				PutResponse: &v1.PutResponse{},
+			},
+		}
+
+		pool = connector.NewPool()
+		pool.SetDialer(connector.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn := new(connectorfakes.FakeConn)
+			replies := []proto.Message{ack}
+			conn.ReadStub = func(b []byte) (int, error) {
+				if len(replies) == 0 {
+					return writeFakeMessage(response, b)
+				}
+				m := replies[0]
+				replies = replies[1:]
+				return writeFakeMessage(m, b)
+			}
+			return conn, nil
+		}))
+		pool.AddServer("server", 40404)
+		connection = connector.NewConnector(pool)
+	})
+
+	It("records the version negotiated with the server", func() {
+		info, err := connection.ServerInfo(context.Background())
+		Expect(err).To(BeNil())
+		Expect(info.Address).To(Equal("server:40404"))
+		Expect(info.Version).To(Equal(connector.ProtocolVersion{Major: 1, Minor: 1}))
+	})
+
+	It("uses the client's minor version with a newer server", func() {
+		ack.ServerMinorVersion = 7
+
+		info, err := connection.ServerInfo(context.Background())
+		Expect(err).To(BeNil())
+		Expect(info.Version.String()).To(Equal("1.1"))
+	})
+
+	It("rejects a server reporting the invalid minor version 0", func() {
+		ack.ServerMajorVersion = 1
+		ack.ServerMinorVersion = 0
+
+		_, err := connection.ServerInfo(context.Background())
+		Expect(err).To(MatchError("server protocol version 1.0 is incompatible with client version 1.1"))
+	})
+
+	It("assumes a server which does not report its version speaks the client's", func() {
+		ack.ServerMajorVersion = 0
+		ack.ServerMinorVersion = 0
+
+		info, err := connection.ServerInfo(context.Background())
+		Expect(err).To(BeNil())
+		Expect(info.Version.String()).To(Equal("1.1"))
+	})
+
+	It("rejects a server with a different major version", func() {
+		ack.ServerMajorVersion = 2
+
+		_, err := connection.ServerInfo(context.Background())
+		Expect(err).To(MatchError("server protocol version 2.1 is incompatible with client version 1.1"))
+	})
+
+	It("describes operations rejected as unsupported by the server", func() {
+		response = &v1.Message{
+			MessageType: &v1.Message_ErrorResponse{
+				ErrorResponse: &v1.ErrorResponse{
+					Error: &v1.Error{
+						ErrorCode: v1.ErrorCode_UNSUPPORTED_OPERATION,
+						Message:   "unsupported operation",
+					},
+				},
+			},
+		}
+
+		err := connection.PutIfAbsent("foo", "a", 1)
+
+		var unsupported *connector.UnsupportedOperationError
+		Expect(errors.As(err, &unsupported)).To(BeTrue())
+		Expect(unsupported.Operation).To(Equal("PutIfAbsent"))
+		Expect(err).To(MatchError("PutIfAbsent is unsupported by server (protocol version 1.1)"))
+	})
+})